@@ -0,0 +1,471 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// alertConfig is where alerts are sent and what raises them. Without rules the defaultAlertRules
+// apply.
+type alertConfig struct {
+	Webhooks []webhook   `json:"webhooks"`
+	Rules    []alertRule `json:"rules"`
+}
+
+// webhook is an endpoint alerts are POSTed to. Format is slack, teams or json; left out it is
+// guessed from the URL.
+type webhook struct {
+	URL    string `json:"url"`
+	Format string `json:"format"`
+}
+
+// alertRule raises an alert for everything in a scan that crosses its threshold. What the
+// threshold means depends on the type:
+//
+//	pending-pods      more pending pods in a namespace than this (default 0)
+//	node-utilization  a node's CPU or memory requests above this percent of allocatable (default 85)
+//	helm-stuck        a Helm release pending for at least this many minutes (default 15)
+//	crashloop         more crashlooping pods in a workload than this (default 0)
+//	empty-namespace   an empty namespace older than this many days (default 30)
+type alertRule struct {
+	Name              string   `json:"name"`
+	Type              string   `json:"type"`
+	Threshold         float64  `json:"threshold"`
+	Namespaces        []string `json:"namespaces"`
+	ExcludeNamespaces []string `json:"excludeNamespaces"`
+}
+
+var defaultAlertRules = []alertRule{
+	{Type: "pending-pods"},
+	{Type: "node-utilization"},
+	{Type: "helm-stuck"},
+	{Type: "crashloop"},
+	{Type: "empty-namespace"},
+}
+
+// alertRuleType is how one type of rule is checked. fullScan rules need secrets or ConfigMaps,
+// which watch mode does not keep, so they only run after a one-shot scan.
+type alertRuleType struct {
+	threshold float64
+	fullScan  bool
+	evaluate  func(rule alertCheck, cluster clusterDetail, now time.Time) []alert
+}
+
+var alertRuleTypes = map[string]alertRuleType{
+	"pending-pods":     {threshold: 0, evaluate: pendingPodAlerts},
+	"node-utilization": {threshold: 85, evaluate: nodeUtilizationAlerts},
+	"helm-stuck":       {threshold: 15, fullScan: true, evaluate: helmStuckAlerts},
+	"crashloop":        {threshold: 0, evaluate: crashloopAlerts},
+	"empty-namespace":  {threshold: 30, fullScan: true, evaluate: emptyNamespaceAlerts},
+}
+
+// alertCheck is a rule ready to run: defaults filled in and namespace patterns compiled.
+type alertCheck struct {
+	alertRuleType
+	name      string
+	threshold float64
+	filter    scanFilter
+}
+
+// alert is one thing a rule found. subject is what it is about, e.g. a namespace, a node or a
+// namespace/workload, so the same problem found again on the next scan has the same key.
+type alert struct {
+	rule    string
+	cluster string
+	subject string
+	message string
+}
+
+func (a alert) key() string {
+	return a.rule + "|" + a.cluster + "|" + a.subject
+}
+
+// alertState is what the state file remembers of an alert that is still firing.
+type alertState struct {
+	Rule      string    `json:"rule"`
+	Cluster   string    `json:"cluster"`
+	FirstSeen time.Time `json:"firstSeen"`
+	LastSent  time.Time `json:"lastSent"`
+}
+
+// alerter checks scans against the rules and sends what is new, or firing again after the
+// cooldown, to every webhook.
+type alerter struct {
+	checks    []alertCheck
+	webhooks  []webhook
+	statePath string
+	cooldown  time.Duration
+}
+
+// defaultAlertStatePath is $XDG_STATE_HOME/kube-helper/alerts.json, or ~/.local/state/kube-helper/alerts.json.
+func defaultAlertStatePath() string {
+	if dir := os.Getenv("XDG_STATE_HOME"); dir != "" {
+		return filepath.Join(dir, "kube-helper", "alerts.json")
+	}
+	if home := homeDir(); home != "" {
+		return filepath.Join(home, ".local", "state", "kube-helper", "alerts.json")
+	}
+	return ""
+}
+
+// webhookFormat guesses the payload format from a webhook URL when none was given.
+func webhookFormat(url string) string {
+	switch {
+	case strings.Contains(url, "hooks.slack.com"):
+		return "slack"
+	case strings.Contains(url, "webhook.office.com"), strings.Contains(url, ".logic.azure.com"):
+		return "teams"
+	}
+	return "json"
+}
+
+// newAlerter combines the configured webhooks with the ones given as flags. It returns nil when
+// there is nowhere to send alerts.
+func newAlerter(cfg alertConfig, urls []string, statePath string, cooldown time.Duration) (*alerter, error) {
+	var webhooks []webhook
+	for _, url := range urls {
+		webhooks = append(webhooks, webhook{URL: url})
+	}
+	webhooks = append(webhooks, cfg.Webhooks...)
+	if len(webhooks) == 0 {
+		return nil, nil
+	}
+	for i, hook := range webhooks {
+		if !strings.HasPrefix(hook.URL, "http://") && !strings.HasPrefix(hook.URL, "https://") {
+			return nil, fmt.Errorf("alert webhook %q is not an http(s) URL", hook.URL)
+		}
+		switch hook.Format {
+		case "":
+			webhooks[i].Format = webhookFormat(hook.URL)
+		case "slack", "teams", "json":
+		default:
+			return nil, fmt.Errorf("alert webhook %s: unknown format %q, expected slack, teams or json", hook.URL, hook.Format)
+		}
+	}
+	if statePath == "" {
+		return nil, fmt.Errorf("alerts need -alert-state to remember what was already sent")
+	}
+
+	rules := cfg.Rules
+	if len(rules) == 0 {
+		rules = defaultAlertRules
+	}
+	a := &alerter{webhooks: webhooks, statePath: statePath, cooldown: cooldown}
+	var names = make(map[string]bool)
+	for _, rule := range rules {
+		kind, ok := alertRuleTypes[rule.Type]
+		if !ok {
+			return nil, fmt.Errorf("unknown alert rule type %q, expected pending-pods, node-utilization, helm-stuck, crashloop or empty-namespace", rule.Type)
+		}
+		check := alertCheck{alertRuleType: kind, name: rule.Name, threshold: kind.threshold}
+		if check.name == "" {
+			check.name = rule.Type
+		}
+		if names[check.name] {
+			return nil, fmt.Errorf("alert rule %s is defined twice, give one a name", check.name)
+		}
+		names[check.name] = true
+		if rule.Threshold != 0 {
+			check.threshold = rule.Threshold
+		}
+		filter, err := newScanFilter(rule.Namespaces, rule.ExcludeNamespaces, false, "", "")
+		if err != nil {
+			return nil, fmt.Errorf("alert rule %s: %v", check.name, err)
+		}
+		check.filter = filter
+		a.checks = append(a.checks, check)
+	}
+	return a, nil
+}
+
+func pendingPodAlerts(rule alertCheck, cluster clusterDetail, now time.Time) []alert {
+	var alerts []alert
+	for _, name := range sortedNamespaceNames(cluster.namespaces) {
+		if !rule.filter.includesNamespace(name) {
+			continue
+		}
+		pending := 0
+		for _, pod := range cluster.namespaces[name].pods {
+			if pod.status == "Pending" {
+				pending++
+			}
+		}
+		if float64(pending) > rule.threshold {
+			alerts = append(alerts, alert{subject: name, message: fmt.Sprintf("%d pods pending in namespace %s", pending, name)})
+		}
+	}
+	return alerts
+}
+
+func nodeUtilizationAlerts(rule alertCheck, cluster clusterDetail, now time.Time) []alert {
+	var alerts []alert
+	for _, node := range nodeUsage([]clusterDetail{cluster}) {
+		if node.CPUAllocatableMilli == 0 || node.MemoryAllocatableBytes == 0 {
+			continue
+		}
+		cpu := float64(node.CPURequestMilli) / float64(node.CPUAllocatableMilli) * 100
+		memory := float64(node.MemoryRequestBytes) / float64(node.MemoryAllocatableBytes) * 100
+		if cpu > rule.threshold || memory > rule.threshold {
+			alerts = append(alerts, alert{subject: node.Name,
+				message: fmt.Sprintf("node %s (%s) has %.0f%% of its CPU and %.0f%% of its memory requested", node.Name, node.Group, cpu, memory)})
+		}
+	}
+	return alerts
+}
+
+func helmStuckAlerts(rule alertCheck, cluster clusterDetail, now time.Time) []alert {
+	var alerts []alert
+	for _, name := range sortedNamespaceNames(cluster.namespaces) {
+		if !rule.filter.includesNamespace(name) {
+			continue
+		}
+		for _, secret := range cluster.namespaces[name].secrets {
+			if !helmPending(secret) {
+				continue
+			}
+			pending := now.Sub(secret.data.CreationTimestamp.Time)
+			if pending.Minutes() < rule.threshold {
+				continue
+			}
+			release := secret.data.GetLabels()["name"]
+			if release == "" {
+				release = secret.name
+			}
+			alerts = append(alerts, alert{subject: name + "/" + release,
+				message: fmt.Sprintf("Helm release %s/%s has been %s for %s", name, release, settings.HelmPendingLabel, pending.Round(time.Minute))})
+		}
+	}
+	return alerts
+}
+
+func crashloopAlerts(rule alertCheck, cluster clusterDetail, now time.Time) []alert {
+	var alerts []alert
+	for _, name := range sortedNamespaceNames(cluster.namespaces) {
+		if !rule.filter.includesNamespace(name) {
+			continue
+		}
+		var crashing = make(map[string]int)
+		var reasons = make(map[string]string)
+		for _, pod := range cluster.namespaces[name].pods {
+			if pod.diagnosis.reason == "CrashLoopBackOff" || pod.diagnosis.reason == "OOMKilled" {
+				workload := workloadOf(pod)
+				crashing[workload]++
+				reasons[workload] = pod.diagnosis.reason
+			}
+		}
+		var workloads []string
+		for workload := range crashing {
+			workloads = append(workloads, workload)
+		}
+		sort.Strings(workloads)
+		for _, workload := range workloads {
+			if float64(crashing[workload]) > rule.threshold {
+				alerts = append(alerts, alert{subject: name + "/" + workload,
+					message: fmt.Sprintf("%d pods of %s/%s are crashlooping (%s)", crashing[workload], name, workload, reasons[workload])})
+			}
+		}
+	}
+	return alerts
+}
+
+// emptyNamespaceAlerts uses the namespace report's idea of empty, and leaves out the namespaces
+// every cluster has.
+func emptyNamespaceAlerts(rule alertCheck, cluster clusterDetail, now time.Time) []alert {
+	var alerts []alert
+	builtIn := map[string]bool{"default": true}
+	for _, name := range systemNamespaces {
+		builtIn[name] = true
+	}
+	for _, name := range sortedNamespaceNames(cluster.namespaces) {
+		ns := cluster.namespaces[name]
+		if builtIn[name] || !rule.filter.includesNamespace(name) || ns.created.IsZero() {
+			continue
+		}
+		if len(ns.pods)+len(ns.virtualServices)+len(ns.ingresses)+len(ns.configMaps)+len(ns.secrets)+len(ns.cronJobs) > 0 {
+			continue
+		}
+		days := now.Sub(ns.created).Hours() / 24
+		if days > rule.threshold {
+			alerts = append(alerts, alert{subject: name, message: fmt.Sprintf("namespace %s has been empty and is %.0f days old", name, days)})
+		}
+	}
+	return alerts
+}
+
+func (a *alerter) loadState() (map[string]alertState, error) {
+	var state = make(map[string]alertState)
+	data, err := os.ReadFile(a.statePath)
+	if os.IsNotExist(err) {
+		return state, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("%s: %v", a.statePath, err)
+	}
+	return state, nil
+}
+
+// saveState writes the state next to the old one and renames it over, so an interrupted write
+// never loses what was sent.
+func (a *alerter) saveState(state map[string]alertState) error {
+	if err := os.MkdirAll(filepath.Dir(a.statePath), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	temp := a.statePath + ".tmp"
+	if err := os.WriteFile(temp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(temp, a.statePath)
+}
+
+// check runs every rule against the clusters and sends the alerts that are new or whose cooldown
+// is over. Alerts that stopped firing are forgotten, so they are sent again as soon as they come
+// back. watching skips the rules that need a full scan.
+func (a *alerter) check(clusters []clusterDetail, watching bool) error {
+	now := time.Now()
+	state, err := a.loadState()
+	if err != nil {
+		return err
+	}
+
+	var firing = make(map[string]bool)
+	var checked = make(map[string]bool)
+	var send []alert
+	for _, cluster := range clusters {
+		for _, check := range a.checks {
+			if watching && check.fullScan {
+				continue
+			}
+			checked[check.name+"|"+cluster.name] = true
+			for _, found := range check.evaluate(check, cluster, now) {
+				found.rule, found.cluster = check.name, cluster.name
+				key := found.key()
+				if firing[key] {
+					continue
+				}
+				firing[key] = true
+				previous, seen := state[key]
+				if seen && now.Sub(previous.LastSent) < a.cooldown {
+					continue
+				}
+				if !seen {
+					previous = alertState{Rule: found.rule, Cluster: found.cluster, FirstSeen: now}
+				}
+				previous.LastSent = now
+				state[key] = previous
+				send = append(send, found)
+			}
+		}
+	}
+	for key, previous := range state {
+		if checked[previous.Rule+"|"+previous.Cluster] && !firing[key] {
+			delete(state, key)
+		}
+	}
+
+	// The state is saved even when a webhook failed: send already rolled back what nobody got.
+	var sendErr error
+	if len(send) > 0 {
+		sendErr = a.send(send, state, now)
+	}
+	if err := a.saveState(state); err != nil {
+		return err
+	}
+	return sendErr
+}
+
+// send POSTs the alerts to every webhook. They count as sent when at least one webhook took
+// them, so a single broken endpoint does not repeat them everywhere else on every scan.
+func (a *alerter) send(alerts []alert, state map[string]alertState, now time.Time) error {
+	var failures []string
+	for _, hook := range a.webhooks {
+		data, err := json.Marshal(alertPayload(hook.Format, alerts, state, now))
+		if err != nil {
+			return err
+		}
+		if err := postWebhook(hook.URL, data); err != nil {
+			failures = append(failures, err.Error())
+		}
+	}
+	if len(failures) == len(a.webhooks) {
+		for _, found := range alerts {
+			if previous := state[found.key()]; previous.FirstSeen.Equal(now) {
+				delete(state, found.key())
+			} else {
+				previous.LastSent = time.Time{}
+				state[found.key()] = previous
+			}
+		}
+	}
+	fmt.Fprintf(os.Stderr, "Sent %d alerts to %d of %d webhooks\n", len(alerts), len(a.webhooks)-len(failures), len(a.webhooks))
+	if len(failures) > 0 {
+		return fmt.Errorf("%s", strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+// alertPayload is the body for one webhook format: Slack's incoming webhook text, a Teams
+// MessageCard, or the alerts as plain JSON for anything else.
+func alertPayload(format string, alerts []alert, state map[string]alertState, now time.Time) interface{} {
+	title := fmt.Sprintf("kube-helper raised %d alerts", len(alerts))
+	if len(alerts) == 1 {
+		title = "kube-helper raised an alert"
+	}
+	var lines []string
+	for _, found := range alerts {
+		lines = append(lines, fmt.Sprintf("[%s] %s", found.cluster, found.message))
+	}
+
+	switch format {
+	case "slack":
+		return map[string]string{"text": "*" + title + "*\n• " + strings.Join(lines, "\n• ")}
+	case "teams":
+		return map[string]string{
+			"@type":      "MessageCard",
+			"@context":   "https://schema.org/extensions",
+			"summary":    title,
+			"title":      title,
+			"themeColor": "D70000",
+			"text":       strings.Join(lines, "\n\n"),
+		}
+	}
+
+	type jsonAlert struct {
+		Rule      string    `json:"rule"`
+		Cluster   string    `json:"cluster"`
+		Subject   string    `json:"subject"`
+		Message   string    `json:"message"`
+		FirstSeen time.Time `json:"firstSeen"`
+	}
+	var items []jsonAlert
+	for _, found := range alerts {
+		items = append(items, jsonAlert{Rule: found.rule, Cluster: found.cluster, Subject: found.subject,
+			Message: found.message, FirstSeen: state[found.key()].FirstSeen.UTC()})
+	}
+	return map[string]interface{}{"generated": now.UTC(), "alerts": items}
+}
+
+func postWebhook(url string, data []byte) error {
+	client := http.Client{Timeout: 30 * time.Second}
+	response, err := client.Post(url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode/100 != 2 {
+		return fmt.Errorf("%s answered %s", url, response.Status)
+	}
+	return nil
+}
@@ -0,0 +1,380 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// webhookServer records the bodies POSTed to it and answers every one with status.
+type webhookServer struct {
+	*httptest.Server
+	mu     sync.Mutex
+	bodies [][]byte
+}
+
+func newWebhookServer(t *testing.T, status int) *webhookServer {
+	s := &webhookServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		s.mu.Lock()
+		s.bodies = append(s.bodies, body)
+		s.mu.Unlock()
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *webhookServer) received() [][]byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([][]byte(nil), s.bodies...)
+}
+
+// pendingCluster has pending pods in namespace web when pending is true, and nothing to alert
+// on otherwise.
+func pendingCluster(pending bool) []clusterDetail {
+	status := "Running"
+	if pending {
+		status = "Pending"
+	}
+	return []clusterDetail{{name: "prod", namespaces: map[string]nameSpaceDetail{
+		"web": {name: "web", pods: []podInfo{{name: "web-1", status: status}}},
+	}}}
+}
+
+func newTestAlerter(t *testing.T, cooldown time.Duration, hooks ...webhook) *alerter {
+	t.Helper()
+	a, err := newAlerter(alertConfig{Webhooks: hooks, Rules: []alertRule{{Type: "pending-pods"}}}, nil,
+		filepath.Join(t.TempDir(), "alerts.json"), cooldown)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func savedState(t *testing.T, a *alerter) map[string]alertState {
+	t.Helper()
+	state, err := a.loadState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return state
+}
+
+func TestAlertPayloads(t *testing.T) {
+	slack := newWebhookServer(t, http.StatusOK)
+	teams := newWebhookServer(t, http.StatusOK)
+	plain := newWebhookServer(t, http.StatusOK)
+	a := newTestAlerter(t, time.Hour,
+		webhook{URL: slack.URL, Format: "slack"},
+		webhook{URL: teams.URL, Format: "teams"},
+		webhook{URL: plain.URL})
+	if err := a.check(pendingCluster(true), false); err != nil {
+		t.Fatal(err)
+	}
+
+	var slackBody map[string]string
+	if err := json.Unmarshal(slack.received()[0], &slackBody); err != nil {
+		t.Fatal(err)
+	}
+	if want := "*kube-helper raised an alert*\n• [prod] 1 pods pending in namespace web"; slackBody["text"] != want {
+		t.Errorf("slack text %q, want %q", slackBody["text"], want)
+	}
+
+	var teamsBody map[string]string
+	if err := json.Unmarshal(teams.received()[0], &teamsBody); err != nil {
+		t.Fatal(err)
+	}
+	if teamsBody["@type"] != "MessageCard" || teamsBody["title"] != "kube-helper raised an alert" ||
+		teamsBody["themeColor"] != "D70000" || teamsBody["text"] != "[prod] 1 pods pending in namespace web" {
+		t.Errorf("teams card %v", teamsBody)
+	}
+
+	var plainBody struct {
+		Generated time.Time `json:"generated"`
+		Alerts    []struct {
+			Rule      string    `json:"rule"`
+			Cluster   string    `json:"cluster"`
+			Subject   string    `json:"subject"`
+			Message   string    `json:"message"`
+			FirstSeen time.Time `json:"firstSeen"`
+		} `json:"alerts"`
+	}
+	if err := json.Unmarshal(plain.received()[0], &plainBody); err != nil {
+		t.Fatal(err)
+	}
+	if len(plainBody.Alerts) != 1 || plainBody.Generated.IsZero() {
+		t.Fatalf("json payload %+v", plainBody)
+	}
+	if got := plainBody.Alerts[0]; got.Rule != "pending-pods" || got.Cluster != "prod" || got.Subject != "web" ||
+		got.Message != "1 pods pending in namespace web" || !got.FirstSeen.Equal(plainBody.Generated) {
+		t.Errorf("json alert %+v", got)
+	}
+}
+
+func TestWebhookFormat(t *testing.T) {
+	for url, want := range map[string]string{
+		"https://hooks.slack.com/services/T0/B0/x":          "slack",
+		"https://example.webhook.office.com/webhookb2/x":    "teams",
+		"https://prod-1.westus.logic.azure.com/workflows/x": "teams",
+		"https://alerts.example.com/kube-helper":            "json",
+	} {
+		if got := webhookFormat(url); got != want {
+			t.Errorf("webhookFormat(%s) = %s, want %s", url, got, want)
+		}
+	}
+}
+
+func TestAlertDedupeAndCooldown(t *testing.T) {
+	server := newWebhookServer(t, http.StatusOK)
+	a := newTestAlerter(t, time.Hour, webhook{URL: server.URL})
+
+	for i := 0; i < 2; i++ {
+		if err := a.check(pendingCluster(true), false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := len(server.received()); got != 1 {
+		t.Fatalf("%d posts for an alert firing twice within the cooldown, want 1", got)
+	}
+	first := savedState(t, a)["pending-pods|prod|web"]
+
+	// Once the cooldown is over the alert goes out again but keeps when it was first seen.
+	a.cooldown = 0
+	if err := a.check(pendingCluster(true), false); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(server.received()); got != 2 {
+		t.Fatalf("%d posts after the cooldown, want 2", got)
+	}
+	again := savedState(t, a)["pending-pods|prod|web"]
+	if !again.FirstSeen.Equal(first.FirstSeen) || !again.LastSent.After(first.LastSent) {
+		t.Errorf("state after the cooldown %+v, was %+v", again, first)
+	}
+}
+
+func TestAlertForgottenWhenResolved(t *testing.T) {
+	server := newWebhookServer(t, http.StatusOK)
+	a := newTestAlerter(t, time.Hour, webhook{URL: server.URL})
+
+	if err := a.check(pendingCluster(true), false); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.check(pendingCluster(false), false); err != nil {
+		t.Fatal(err)
+	}
+	if state := savedState(t, a); len(state) != 0 {
+		t.Errorf("resolved alert still in the state: %v", state)
+	}
+	if got := len(server.received()); got != 1 {
+		t.Fatalf("%d posts after the alert resolved, want 1", got)
+	}
+
+	// Coming back is a new alert even within the cooldown.
+	if err := a.check(pendingCluster(true), false); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(server.received()); got != 2 {
+		t.Errorf("%d posts after the alert came back, want 2", got)
+	}
+}
+
+func TestAlertPartialFailure(t *testing.T) {
+	good := newWebhookServer(t, http.StatusOK)
+	broken := newWebhookServer(t, http.StatusInternalServerError)
+	a := newTestAlerter(t, time.Hour, webhook{URL: good.URL}, webhook{URL: broken.URL})
+
+	err := a.check(pendingCluster(true), false)
+	if err == nil || !strings.Contains(err.Error(), broken.URL) {
+		t.Fatalf("check returned %v, want the broken webhook's error", err)
+	}
+	if _, ok := savedState(t, a)["pending-pods|prod|web"]; !ok {
+		t.Fatal("an alert one webhook took was not saved")
+	}
+
+	// It counts as sent, so it is not repeated to either webhook.
+	if err := a.check(pendingCluster(true), false); err != nil {
+		t.Fatal(err)
+	}
+	if len(good.received()) != 1 || len(broken.received()) != 1 {
+		t.Errorf("%d and %d posts after a partial failure, want 1 each", len(good.received()), len(broken.received()))
+	}
+}
+
+func TestAlertAllWebhooksFail(t *testing.T) {
+	broken := newWebhookServer(t, http.StatusBadGateway)
+	a := newTestAlerter(t, time.Hour, webhook{URL: broken.URL})
+
+	if err := a.check(pendingCluster(true), false); err == nil {
+		t.Fatal("check did not report the failed webhook")
+	}
+	if state := savedState(t, a); len(state) != 0 {
+		t.Errorf("an alert nobody got was remembered: %v", state)
+	}
+	if err := a.check(pendingCluster(true), false); err == nil {
+		t.Fatal("check did not report the failed webhook again")
+	}
+	if got := len(broken.received()); got != 2 {
+		t.Errorf("%d posts, want the alert retried on the next check", got)
+	}
+}
+
+// ruleCheck builds a rule the way newAlerter does, defaults and namespace filters included.
+func ruleCheck(t *testing.T, rule alertRule) alertCheck {
+	t.Helper()
+	a, err := newAlerter(alertConfig{Rules: []alertRule{rule}}, []string{"http://alerts.example"},
+		filepath.Join(t.TempDir(), "alerts.json"), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a.checks[0]
+}
+
+type evaluatorTest struct {
+	name     string
+	rule     alertRule
+	subjects []string
+}
+
+// runEvaluatorTests checks that every rule raises alerts about exactly the given subjects, in order.
+func runEvaluatorTests(t *testing.T, cluster clusterDetail, now time.Time, tests []evaluatorTest) {
+	t.Helper()
+	for _, test := range tests {
+		check := ruleCheck(t, test.rule)
+		var subjects []string
+		for _, found := range check.evaluate(check, cluster, now) {
+			subjects = append(subjects, found.subject)
+		}
+		if strings.Join(subjects, ",") != strings.Join(test.subjects, ",") {
+			t.Errorf("%s: alerts about %v, want %v", test.name, subjects, test.subjects)
+		}
+	}
+}
+
+func allocatableNode(name, cpu, memory string) v1.Node {
+	return v1.Node{ObjectMeta: metav1.ObjectMeta{Name: name}, Status: v1.NodeStatus{Allocatable: v1.ResourceList{
+		v1.ResourceCPU:    resource.MustParse(cpu),
+		v1.ResourceMemory: resource.MustParse(memory),
+	}}}
+}
+
+func TestNodeUtilizationAlerts(t *testing.T) {
+	cluster := clusterDetail{name: "prod",
+		nodeList: []v1.Node{
+			allocatableNode("at-threshold", "4", "16Gi"),
+			allocatableNode("cpu-over", "4", "16Gi"),
+			allocatableNode("memory-over", "4", "16Gi"),
+			allocatableNode("no-allocatable", "0", "0"),
+		},
+		namespaces: map[string]nameSpaceDetail{
+			"web": {name: "web", pods: []podInfo{
+				{name: "a", nodeName: "at-threshold", status: "Running", reservedCPU: 3400, reservedMemory: 8 * gib},
+				{name: "b", nodeName: "cpu-over", status: "Running", reservedCPU: 3401},
+				{name: "c", nodeName: "memory-over", status: "Running", reservedCPU: 100, reservedMemory: 14 * gib},
+				{name: "d", nodeName: "no-allocatable", status: "Running", reservedCPU: 1000, reservedMemory: gib},
+			}},
+			// Finished pods hold no requests.
+			"jobs": {name: "jobs", pods: []podInfo{
+				{name: "e", nodeName: "at-threshold", status: "Completed", reservedCPU: 4000},
+				{name: "f", nodeName: "at-threshold", status: "Failed", reservedMemory: 16 * gib},
+			}},
+		},
+	}
+	runEvaluatorTests(t, cluster, time.Now(), []evaluatorTest{
+		{"default 85%", alertRule{Type: "node-utilization"}, []string{"cpu-over", "memory-over"}},
+		{"50%", alertRule{Type: "node-utilization", Threshold: 50}, []string{"at-threshold", "cpu-over", "memory-over"}},
+		{"90%", alertRule{Type: "node-utilization", Threshold: 90}, nil},
+	})
+}
+
+func TestHelmStuckAlerts(t *testing.T) {
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	release := func(name, release, status string, age time.Duration) secretInfo {
+		labels := map[string]string{"owner": "helm", "status": status}
+		if release != "" {
+			labels["name"] = release
+		}
+		return secretInfo{name: name, data: v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels,
+			CreationTimestamp: metav1.NewTime(now.Add(-age))}}}
+	}
+	cluster := clusterDetail{name: "prod", namespaces: map[string]nameSpaceDetail{
+		"web": {name: "web", secrets: []secretInfo{
+			release("sh.helm.release.v1.api.v3", "api", "pending-update", 15*time.Minute),
+			release("sh.helm.release.v1.www.v2", "www", "pending-update", 14*time.Minute),
+			release("sh.helm.release.v1.cdn.v9", "cdn", "deployed", 48*time.Hour),
+			release("unlabelled", "", "pending-update", time.Hour),
+		}},
+		"team-db": {name: "team-db", secrets: []secretInfo{
+			release("sh.helm.release.v1.pg.v4", "pg", "pending-update", time.Hour),
+		}},
+	}}
+	runEvaluatorTests(t, cluster, now, []evaluatorTest{
+		{"default 15 minutes", alertRule{Type: "helm-stuck"}, []string{"team-db/pg", "web/api", "web/unlabelled"}},
+		{"10 minutes", alertRule{Type: "helm-stuck", Threshold: 10}, []string{"team-db/pg", "web/api", "web/www", "web/unlabelled"}},
+		{"2 hours", alertRule{Type: "helm-stuck", Threshold: 120}, nil},
+		{"excluded namespace", alertRule{Type: "helm-stuck", ExcludeNamespaces: []string{"team-*"}}, []string{"web/api", "web/unlabelled"}},
+	})
+}
+
+func TestCrashloopAlerts(t *testing.T) {
+	crashing := func(name, owner, reason string) podInfo {
+		return podInfo{name: name, ownerName: owner, status: "Running", diagnosis: podDiagnosis{reason: reason}}
+	}
+	cluster := clusterDetail{name: "prod", namespaces: map[string]nameSpaceDetail{
+		"web": {name: "web", pods: []podInfo{
+			crashing("api-1", "api", "CrashLoopBackOff"),
+			crashing("api-2", "api", "OOMKilled"),
+			crashing("www-1", "www", "CrashLoopBackOff"),
+			crashing("cdn-1", "cdn", "ImagePullBackOff"),
+			crashing("cdn-2", "cdn", ""),
+		}},
+		"batch": {name: "batch", pods: []podInfo{
+			crashing("report", "", "OOMKilled"),
+		}},
+	}}
+	runEvaluatorTests(t, cluster, time.Now(), []evaluatorTest{
+		{"default any pod", alertRule{Type: "crashloop"}, []string{"batch/report", "web/api", "web/www"}},
+		{"more than one pod", alertRule{Type: "crashloop", Threshold: 1}, []string{"web/api"}},
+		{"more than two pods", alertRule{Type: "crashloop", Threshold: 2}, nil},
+		{"only web", alertRule{Type: "crashloop", Namespaces: []string{"web"}}, []string{"web/api", "web/www"}},
+	})
+}
+
+func TestEmptyNamespaceAlerts(t *testing.T) {
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	empty := func(name string, days int) nameSpaceDetail {
+		return nameSpaceDetail{name: name, created: now.AddDate(0, 0, -days)}
+	}
+	withConfigMap := empty("settings", 400)
+	withConfigMap.configMaps = []configMapInfo{{name: "flags"}}
+	cluster := clusterDetail{name: "prod", namespaces: map[string]nameSpaceDetail{
+		"abandoned":       empty("abandoned", 31),
+		"at-threshold":    empty("at-threshold", 30),
+		"new":             empty("new", 2),
+		"settings":        withConfigMap,
+		"team-old":        empty("team-old", 90),
+		"unknown-age":     {name: "unknown-age"},
+		"default":         empty("default", 400),
+		"kube-public":     empty("kube-public", 400),
+		"kube-node-lease": empty("kube-node-lease", 400),
+	}}
+	runEvaluatorTests(t, cluster, now, []evaluatorTest{
+		{"default 30 days", alertRule{Type: "empty-namespace"}, []string{"abandoned", "team-old"}},
+		{"a day", alertRule{Type: "empty-namespace", Threshold: 1}, []string{"abandoned", "at-threshold", "new", "team-old"}},
+		// Built in namespaces stay out even when a rule names them.
+		{"built in", alertRule{Type: "empty-namespace", Namespaces: []string{"default", "kube-*"}}, nil},
+		{"excluded namespace", alertRule{Type: "empty-namespace", ExcludeNamespaces: []string{"/^team-/"}}, []string{"abandoned"}},
+	})
+}
@@ -0,0 +1,335 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// JSON views of the scan model served by the serve command. Every list is sorted so responses
+// are stable between scans.
+type apiCluster struct {
+	Name               string    `json:"name"`
+	Namespaces         int       `json:"namespaces"`
+	Pods               int       `json:"pods"`
+	Nodes              int       `json:"nodes"`
+	CPURequestMilli    int64     `json:"cpuRequestMilli"`
+	MemoryRequestBytes int64     `json:"memoryRequestBytes"`
+	ScannedAt          time.Time `json:"scannedAt"`
+}
+
+type apiNamespace struct {
+	Cluster            string            `json:"cluster"`
+	Name               string            `json:"name"`
+	Labels             map[string]string `json:"labels,omitempty"`
+	Pods               int               `json:"pods"`
+	Status             map[string]int    `json:"status"`
+	Workloads          int               `json:"workloads"`
+	CPURequestMilli    int64             `json:"cpuRequestMilli"`
+	MemoryRequestBytes int64             `json:"memoryRequestBytes"`
+}
+
+type apiWorkload struct {
+	Cluster            string         `json:"cluster"`
+	Namespace          string         `json:"namespace"`
+	Name               string         `json:"name"`
+	Kind               string         `json:"kind"`
+	Pods               int            `json:"pods"`
+	Status             map[string]int `json:"status"`
+	Restarts           int32          `json:"restarts"`
+	CPURequestMilli    int64          `json:"cpuRequestMilli"`
+	MemoryRequestBytes int64          `json:"memoryRequestBytes"`
+}
+
+type apiNode struct {
+	Cluster                string `json:"cluster"`
+	Name                   string `json:"name"`
+	Group                  string `json:"group"`
+	InstanceType           string `json:"instanceType"`
+	Zone                   string `json:"zone"`
+	CapacityType           string `json:"capacityType"`
+	Pods                   int    `json:"pods"`
+	CPUAllocatableMilli    int64  `json:"cpuAllocatableMilli"`
+	MemoryAllocatableBytes int64  `json:"memoryAllocatableBytes"`
+	CPURequestMilli        int64  `json:"cpuRequestMilli"`
+	MemoryRequestBytes     int64  `json:"memoryRequestBytes"`
+}
+
+type apiImage struct {
+	Cluster         string         `json:"cluster"`
+	Image           string         `json:"image"`
+	Registry        string         `json:"registry"`
+	Repository      string         `json:"repository"`
+	Version         string         `json:"version"`
+	Containers      int            `json:"containers"`
+	RunningDigests  []string       `json:"runningDigests,omitempty"`
+	Vulnerabilities map[string]int `json:"vulnerabilities,omitempty"`
+}
+
+// scanAPI serves the cached scan as JSON:
+//
+//	/clusters
+//	/clusters/{context}/namespaces
+//	/namespaces/{namespace}/workloads
+//	/nodes
+//	/images
+//
+// Endpoints without a cluster in the path cover every cluster unless ?cluster= is given.
+type scanAPI struct {
+	cache  *scanCache
+	vulnDB advisoryDB
+}
+
+func (a scanAPI) register(mux *http.ServeMux) {
+	mux.HandleFunc("/clusters", a.clusters)
+	mux.HandleFunc("/clusters/", a.clusterNamespaces)
+	mux.HandleFunc("/namespaces/", a.workloads)
+	mux.HandleFunc("/nodes", a.nodes)
+	mux.HandleFunc("/images", a.images)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(v)
+}
+
+func notFound(w http.ResponseWriter, message string) {
+	writeJSON(w, http.StatusNotFound, map[string]string{"error": message})
+}
+
+// pathName is the unescaped name between prefix and suffix of the request path, e.g. prod in
+// /clusters/prod/namespaces. The name may hold slashes, plain or as %2F, because EKS contexts
+// are ARNs like arn:aws:eks:us-east-1:123456789012:cluster/prod.
+func pathName(r *http.Request, prefix, suffix string) (string, bool) {
+	path := strings.TrimSuffix(r.URL.EscapedPath(), "/")
+	if !strings.HasPrefix(path, prefix) || !strings.HasSuffix(path, suffix) || len(path) <= len(prefix)+len(suffix) {
+		return "", false
+	}
+	name, err := url.PathUnescape(path[len(prefix) : len(path)-len(suffix)])
+	return name, err == nil
+}
+
+// selected returns the cached clusters filtered by the ?cluster= query parameter.
+func (a scanAPI) selected(r *http.Request) []clusterDetail {
+	clusters, _ := a.cache.get()
+	name := r.URL.Query().Get("cluster")
+	if name == "" {
+		return clusters
+	}
+	var selected []clusterDetail
+	for _, cluster := range clusters {
+		if cluster.name == name {
+			selected = append(selected, cluster)
+		}
+	}
+	return selected
+}
+
+func statusMap(summary podStatusSummary) map[string]int {
+	var status = make(map[string]int)
+	for i, count := range summary.counts() {
+		if count > 0 {
+			status[podStatuses[i]] = count
+		}
+	}
+	return status
+}
+
+// clusterSummaries are the /clusters view of a scan.
+func clusterSummaries(clusters []clusterDetail, scanned time.Time) []apiCluster {
+	var result = []apiCluster{}
+	for _, cluster := range clusters {
+		item := apiCluster{Name: cluster.name, Namespaces: len(cluster.namespaces), Nodes: len(cluster.nodeList), ScannedAt: scanned}
+		for _, ns := range cluster.namespaces {
+			item.Pods += len(ns.pods)
+			item.CPURequestMilli += ns.totalCPURequest
+			item.MemoryRequestBytes += ns.totalRAMRequest
+		}
+		result = append(result, item)
+	}
+	return result
+}
+
+// namespaceSummaries are the namespaces of one cluster, by name.
+func namespaceSummaries(cluster clusterDetail) []apiNamespace {
+	var result = []apiNamespace{}
+	for _, ns := range cluster.namespaces {
+		result = append(result, apiNamespace{
+			Cluster:            cluster.name,
+			Name:               ns.name,
+			Labels:             ns.labels,
+			Pods:               len(ns.pods),
+			Status:             statusMap(ns.statusSummary),
+			Workloads:          len(ns.deployments),
+			CPURequestMilli:    ns.totalCPURequest,
+			MemoryRequestBytes: ns.totalRAMRequest,
+		})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
+
+// workloadSummaries groups the pods of one namespace by workload, by name.
+func workloadSummaries(cluster clusterDetail, ns nameSpaceDetail) []apiWorkload {
+	var result = []apiWorkload{}
+	var workloads = make(map[string]*apiWorkload)
+	var statuses = make(map[string]*podStatusSummary)
+	for _, pod := range ns.pods {
+		name := workloadOf(pod)
+		item, ok := workloads[name]
+		if !ok {
+			kind := pod.ownerKind
+			if kind == "" {
+				kind = "Pod"
+			}
+			item = &apiWorkload{Cluster: cluster.name, Namespace: ns.name, Name: name, Kind: kind}
+			workloads[name] = item
+			statuses[name] = &podStatusSummary{}
+		}
+		item.Pods++
+		item.Restarts += pod.RestartCount
+		item.CPURequestMilli += pod.reservedCPU
+		item.MemoryRequestBytes += pod.reservedMemory
+		statuses[name].add(pod.status)
+	}
+	for name, item := range workloads {
+		item.Status = statusMap(*statuses[name])
+		result = append(result, *item)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
+
+// nodeUsage is every node of the given clusters with what its running pods request.
+func nodeUsage(clusters []clusterDetail) []apiNode {
+	var result = []apiNode{}
+	for _, cluster := range clusters {
+		var usage = make(map[string]*apiNode)
+		for _, ns := range cluster.namespaces {
+			for _, pod := range ns.pods {
+				if pod.nodeName == "" || pod.status == "Completed" || pod.status == "Failed" {
+					continue
+				}
+				if _, ok := usage[pod.nodeName]; !ok {
+					usage[pod.nodeName] = &apiNode{}
+				}
+				usage[pod.nodeName].Pods++
+				usage[pod.nodeName].CPURequestMilli += pod.reservedCPU
+				usage[pod.nodeName].MemoryRequestBytes += pod.reservedMemory
+			}
+		}
+		for _, node := range cluster.nodeList {
+			item := apiNode{
+				Cluster:                cluster.name,
+				Name:                   node.Name,
+				Group:                  nodeGroupOf(node),
+				InstanceType:           node.GetLabels()["beta.kubernetes.io/instance-type"],
+				Zone:                   zoneOf(node),
+				CapacityType:           capacityTypeOf(node),
+				CPUAllocatableMilli:    node.Status.Allocatable.Cpu().MilliValue(),
+				MemoryAllocatableBytes: node.Status.Allocatable.Memory().Value(),
+			}
+			if used, ok := usage[node.Name]; ok {
+				item.Pods, item.CPURequestMilli, item.MemoryRequestBytes = used.Pods, used.CPURequestMilli, used.MemoryRequestBytes
+			}
+			result = append(result, item)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Cluster != result[j].Cluster {
+			return result[i].Cluster < result[j].Cluster
+		}
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+// imageUsage is every image of the given clusters, matched against vulnDB.
+func imageUsage(clusters []clusterDetail, vulnDB advisoryDB) []apiImage {
+	var result = []apiImage{}
+	for _, cluster := range clusters {
+		var images = make(map[string]imageInfo)
+		for _, ns := range cluster.namespaces {
+			for _, info := range ns.images {
+				mergeImage(images, info)
+			}
+		}
+		annotateVulnerabilities(images, vulnDB)
+		for _, info := range images {
+			result = append(result, apiImage{
+				Cluster:         cluster.name,
+				Image:           info.imageKey,
+				Registry:        info.imageRepo,
+				Repository:      info.imageName,
+				Version:         info.imageVersion,
+				Containers:      info.count,
+				RunningDigests:  info.runningDigests,
+				Vulnerabilities: info.vulns,
+			})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Cluster != result[j].Cluster {
+			return result[i].Cluster < result[j].Cluster
+		}
+		return result[i].Image < result[j].Image
+	})
+	return result
+}
+
+func (a scanAPI) clusters(w http.ResponseWriter, r *http.Request) {
+	clusters, scanned := a.cache.get()
+	writeJSON(w, http.StatusOK, clusterSummaries(clusters, scanned))
+}
+
+func (a scanAPI) clusterNamespaces(w http.ResponseWriter, r *http.Request) {
+	name, ok := pathName(r, "/clusters/", "/namespaces")
+	if !ok {
+		notFound(w, "expected /clusters/{context}/namespaces")
+		return
+	}
+	clusters, _ := a.cache.get()
+	for _, cluster := range clusters {
+		if cluster.name == name {
+			writeJSON(w, http.StatusOK, namespaceSummaries(cluster))
+			return
+		}
+	}
+	notFound(w, "unknown cluster "+name)
+}
+
+func (a scanAPI) workloads(w http.ResponseWriter, r *http.Request) {
+	name, ok := pathName(r, "/namespaces/", "/workloads")
+	if !ok {
+		notFound(w, "expected /namespaces/{namespace}/workloads")
+		return
+	}
+	var result = []apiWorkload{}
+	found := false
+	for _, cluster := range a.selected(r) {
+		if ns, ok := cluster.namespaces[name]; ok {
+			found = true
+			result = append(result, workloadSummaries(cluster, ns)...)
+		}
+	}
+	if !found {
+		notFound(w, "unknown namespace "+name)
+		return
+	}
+	sort.SliceStable(result, func(i, j int) bool { return result[i].Cluster < result[j].Cluster })
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (a scanAPI) nodes(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, nodeUsage(a.selected(r)))
+}
+
+func (a scanAPI) images(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, imageUsage(a.selected(r), a.vulnDB))
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const eksContext = "arn:aws:eks:us-east-1:123456789012:cluster/prod"
+
+func newTestAPI() http.Handler {
+	cache := &scanCache{}
+	cache.set([]clusterDetail{
+		{name: eksContext, namespaces: map[string]nameSpaceDetail{
+			"web": {name: "web", pods: []podInfo{{name: "web-1", ownerName: "web", ownerKind: "ReplicaSet", status: "Running"}}},
+		}},
+		{name: "staging", namespaces: map[string]nameSpaceDetail{
+			"web": {name: "web"},
+		}},
+	})
+	mux := http.NewServeMux()
+	scanAPI{cache: cache}.register(mux)
+	return mux
+}
+
+func TestAPIPathsWithSlashes(t *testing.T) {
+	api := newTestAPI()
+	tests := []struct {
+		path     string
+		status   int
+		clusters []string
+	}{
+		{"/clusters/" + eksContext + "/namespaces", http.StatusOK, []string{eksContext}},
+		{"/clusters/arn:aws:eks:us-east-1:123456789012:cluster%2Fprod/namespaces", http.StatusOK, []string{eksContext}},
+		{"/clusters/staging/namespaces/", http.StatusOK, []string{"staging"}},
+		{"/clusters/arn:aws:eks:us-east-1:123456789012:cluster/dev/namespaces", http.StatusNotFound, nil},
+		{"/clusters/staging", http.StatusNotFound, nil},
+		{"/namespaces/web/workloads?cluster=" + eksContext, http.StatusOK, []string{eksContext}},
+		{"/namespaces/web/workloads", http.StatusOK, []string{eksContext}},
+		{"/namespaces/db/workloads", http.StatusNotFound, nil},
+		{"/namespaces/web", http.StatusNotFound, nil},
+	}
+	for _, test := range tests {
+		recorder := httptest.NewRecorder()
+		api.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, test.path, nil))
+		if recorder.Code != test.status {
+			t.Errorf("%s: status %d, want %d: %s", test.path, recorder.Code, test.status, recorder.Body)
+			continue
+		}
+		if test.status != http.StatusOK {
+			continue
+		}
+		var items []struct {
+			Cluster string `json:"cluster"`
+		}
+		if err := json.Unmarshal(recorder.Body.Bytes(), &items); err != nil {
+			t.Fatalf("%s: %v", test.path, err)
+		}
+		var clusters []string
+		for _, item := range items {
+			clusters = append(clusters, item.Cluster)
+		}
+		if len(clusters) != len(test.clusters) || (len(clusters) > 0 && clusters[0] != test.clusters[0]) {
+			t.Errorf("%s: clusters %v, want %v", test.path, clusters, test.clusters)
+		}
+	}
+}
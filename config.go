@@ -0,0 +1,231 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// thresholds are the limits the reports use to call a pod or image out.
+type thresholds struct {
+	// RestartLimit restarts within RestartWindowMinutes make a pod a problem even if it is up now.
+	RestartLimit         int32 `json:"restartLimit"`
+	RestartWindowMinutes int   `json:"restartWindowMinutes"`
+	// Pods requesting at least this much are highlighted in the pod list; 0 turns the check off.
+	PodMemoryWarningMB      int64 `json:"podMemoryWarningMB"`
+	PodCPUWarningMillicores int64 `json:"podCPUWarningMillicores"`
+	LargeImageMB            int64 `json:"largeImageMB"`
+}
+
+// thresholdOverrides are the thresholds as a profile gives them. Only the ones it sets replace
+// the defaults, so an explicit 0 does turn a check off.
+type thresholdOverrides struct {
+	RestartLimit            *int32 `json:"restartLimit"`
+	RestartWindowMinutes    *int   `json:"restartWindowMinutes"`
+	PodMemoryWarningMB      *int64 `json:"podMemoryWarningMB"`
+	PodCPUWarningMillicores *int64 `json:"podCPUWarningMillicores"`
+	LargeImageMB            *int64 `json:"largeImageMB"`
+}
+
+// profile is one named set of defaults in the config file. Anything left out keeps the built in
+// default, and flags given on the command line win over the profile.
+type profile struct {
+	Contexts []string `json:"contexts"`
+	// Reports turns on the reports behind -p, -i, -n, -s, -d and -image-hygiene.
+	Reports    []string           `json:"reports"`
+	Output     string             `json:"output"`
+	Thresholds thresholdOverrides `json:"thresholds"`
+	// NodeGroupKey is the node taint (and pod toleration) nodes are grouped by.
+	NodeGroupKey string `json:"nodeGroupKey"`
+	TeamLabel    string `json:"teamLabel"`
+	// HelmPendingLabel is the key=value label of Helm release secrets stuck mid upgrade.
+	HelmPendingLabel string `json:"helmPendingLabel"`
+	// Alerts are the webhooks scans and watch mode send alerts to, and the rules raising them.
+	Alerts alertConfig `json:"alerts"`
+	// Flags sets any other flag by name, e.g. pricing: prices.yaml.
+	Flags map[string]string `json:"flags"`
+}
+
+type config struct {
+	DefaultProfile string             `json:"defaultProfile"`
+	Profiles       map[string]profile `json:"profiles"`
+}
+
+// runSettings are the parts of a profile the scan and the reports read directly rather than
+// through flags.
+type runSettings struct {
+	Thresholds       thresholds
+	NodeGroupKey     string
+	HelmPendingLabel string
+	Alerts           alertConfig
+}
+
+// settings is what the scan and the reports read instead of constants. main overlays the
+// selected profile on it before scanning.
+var settings = runSettings{
+	Thresholds: thresholds{
+		RestartLimit:         20,
+		RestartWindowMinutes: 20,
+		PodMemoryWarningMB:   1024,
+	},
+	NodeGroupKey:     "workload_type",
+	HelmPendingLabel: "status=pending-update",
+}
+
+// reportFlags maps the report names a profile can enable to the flags that turn them on.
+var reportFlags = map[string]string{
+	"pods":          "p",
+	"images":        "i",
+	"nodes":         "n",
+	"status":        "s",
+	"deprecations":  "d",
+	"image-hygiene": "image-hygiene",
+}
+
+// defaultConfigPath is $XDG_CONFIG_HOME/kube-helper/config.yaml, or ~/.config/kube-helper/config.yaml.
+func defaultConfigPath() string {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "kube-helper", "config.yaml")
+	}
+	if home := homeDir(); home != "" {
+		return filepath.Join(home, ".config", "kube-helper", "config.yaml")
+	}
+	return ""
+}
+
+// loadConfig reads the config file. A missing file is an empty config unless it was asked for
+// explicitly.
+func loadConfig(path string, explicit bool) (config, error) {
+	var cfg config
+	if path == "" {
+		return cfg, nil
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) && !explicit {
+		return cfg, nil
+	} else if err != nil {
+		return cfg, err
+	}
+	if err := yaml.UnmarshalStrict(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("parsing %s: %v", path, err)
+	}
+	return cfg, nil
+}
+
+// selectProfile returns the named profile, or the default one when name is empty. No name and
+// no default means no profile.
+func (c config) selectProfile(name string) (profile, bool, error) {
+	if name == "" {
+		name = c.DefaultProfile
+	}
+	if name == "" {
+		return profile{}, false, nil
+	}
+	p, ok := c.Profiles[name]
+	if !ok {
+		var names []string
+		for known := range c.Profiles {
+			names = append(names, known)
+		}
+		sort.Strings(names)
+		return p, false, fmt.Errorf("unknown profile %q, the config has: %s", name, strings.Join(names, ", "))
+	}
+	return p, true, nil
+}
+
+// flagValues turns the profile into flag values, keyed by flag name.
+func (p profile) flagValues() (map[string]string, error) {
+	var values = make(map[string]string)
+	for name, value := range p.Flags {
+		values[name] = value
+	}
+	if len(p.Contexts) > 0 {
+		values["c"] = strings.Join(p.Contexts, ",")
+	}
+	for _, report := range p.Reports {
+		name, ok := reportFlags[report]
+		if !ok {
+			return nil, fmt.Errorf("unknown report %q, expected pods, images, nodes, status, deprecations or image-hygiene", report)
+		}
+		values[name] = "true"
+	}
+	if p.Output != "" {
+		values["o"] = p.Output
+	}
+	if p.TeamLabel != "" {
+		values["team-label"] = p.TeamLabel
+	}
+	if p.Thresholds.LargeImageMB != nil {
+		values["large-image-mb"] = strconv.FormatInt(*p.Thresholds.LargeImageMB, 10)
+	}
+	return values, nil
+}
+
+// applyProfile sets every flag the profile has a value for and the command line did not set, and
+// overlays the rest of the profile on settings.
+func applyProfile(p profile) error {
+	values, err := p.flagValues()
+	if err != nil {
+		return err
+	}
+	var given = make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		given[f.Name] = true
+	})
+	for name, value := range values {
+		if given[name] {
+			continue
+		}
+		if err := flag.Set(name, value); err != nil {
+			return fmt.Errorf("profile flag %s: %v", name, err)
+		}
+	}
+
+	if p.Thresholds.RestartLimit != nil {
+		settings.Thresholds.RestartLimit = *p.Thresholds.RestartLimit
+	}
+	if p.Thresholds.RestartWindowMinutes != nil {
+		settings.Thresholds.RestartWindowMinutes = *p.Thresholds.RestartWindowMinutes
+	}
+	if p.Thresholds.PodMemoryWarningMB != nil {
+		settings.Thresholds.PodMemoryWarningMB = *p.Thresholds.PodMemoryWarningMB
+	}
+	if p.Thresholds.PodCPUWarningMillicores != nil {
+		settings.Thresholds.PodCPUWarningMillicores = *p.Thresholds.PodCPUWarningMillicores
+	}
+	if p.NodeGroupKey != "" {
+		settings.NodeGroupKey = p.NodeGroupKey
+	}
+	if p.HelmPendingLabel != "" {
+		if !strings.Contains(p.HelmPendingLabel, "=") {
+			return fmt.Errorf("helmPendingLabel %q should look like key=value", p.HelmPendingLabel)
+		}
+		settings.HelmPendingLabel = p.HelmPendingLabel
+	}
+	if len(p.Alerts.Webhooks) > 0 || len(p.Alerts.Rules) > 0 {
+		settings.Alerts = p.Alerts
+	}
+	return nil
+}
+
+// helmPending reports whether a Helm release secret carries the pending label.
+func helmPending(secret secretInfo) bool {
+	parts := strings.SplitN(settings.HelmPendingLabel, "=", 2)
+	value, ok := secret.data.GetLabels()[parts[0]]
+	return ok && len(parts) == 2 && value == parts[1]
+}
+
+// heavyPod reports whether a pod requests more than the pod warning thresholds allow.
+func heavyPod(pod podInfo) bool {
+	limits := settings.Thresholds
+	if limits.PodMemoryWarningMB > 0 && pod.reservedMemory/1024/1024 >= limits.PodMemoryWarningMB {
+		return true
+	}
+	return limits.PodCPUWarningMillicores > 0 && pod.reservedCPU >= limits.PodCPUWarningMillicores
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestProfileThresholdsOverrideDefaults(t *testing.T) {
+	defaults := settings
+	defer func() { settings = defaults }()
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	data := `
+defaultProfile: batch
+profiles:
+  batch:
+    thresholds:
+      podMemoryWarningMB: 0
+      podCPUWarningMillicores: 4000
+`
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := loadConfig(path, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, _, err := cfg.selectProfile("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := applyProfile(p); err != nil {
+		t.Fatal(err)
+	}
+
+	limits := settings.Thresholds
+	if limits.PodMemoryWarningMB != 0 || limits.PodCPUWarningMillicores != 4000 {
+		t.Errorf("pod warnings at %d MB and %dm, want 0 MB and 4000m", limits.PodMemoryWarningMB, limits.PodCPUWarningMillicores)
+	}
+	if limits.RestartLimit != defaults.Thresholds.RestartLimit || limits.RestartWindowMinutes != defaults.Thresholds.RestartWindowMinutes {
+		t.Errorf("restart limit %d in %d minutes, want the defaults", limits.RestartLimit, limits.RestartWindowMinutes)
+	}
+	if heavyPod(podInfo{reservedMemory: 64 * gib, reservedCPU: 1000}) {
+		t.Error("a memory heavy pod was flagged with the memory check off")
+	}
+	if !heavyPod(podInfo{reservedCPU: 4000}) {
+		t.Error("a pod at the CPU threshold was not flagged")
+	}
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+
+	v1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// pricingTable maps instance type to capacity type (ON_DEMAND, SPOT) to an hourly price.
+type pricingTable struct {
+	Instances map[string]map[string]float64 `json:"instances"`
+}
+
+// costLine is one row of a showback table.
+type costLine struct {
+	name    string
+	pods    int
+	cpu     int64
+	ram     int64
+	monthly float64
+}
+
+type costReport struct {
+	cluster     string
+	byNamespace map[string]*costLine
+	byWorkload  map[string]*costLine
+	byTeam      map[string]*costLine
+	total       float64
+	idle        float64
+	unpriced    []string
+}
+
+func loadPricing(path string) (pricingTable, error) {
+	var pricing pricingTable
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return pricing, err
+	}
+	if err := yaml.Unmarshal(data, &pricing); err != nil {
+		return pricing, fmt.Errorf("parsing %s: %v", path, err)
+	}
+	return pricing, nil
+}
+
+// capacityTypeOf reads eks.amazonaws.com/capacityType, which defaults to ON_DEMAND when the
+// label is not there.
+func capacityTypeOf(node v1.Node) string {
+	if capacityType, ok := node.GetLabels()["eks.amazonaws.com/capacityType"]; ok {
+		return capacityType
+	}
+	return "ON_DEMAND"
+}
+
+// nodeHourlyPrice looks up a node by instance type and capacity type.
+func (p pricingTable) nodeHourlyPrice(node v1.Node) (float64, bool) {
+	price, ok := p.Instances[node.GetLabels()["beta.kubernetes.io/instance-type"]][capacityTypeOf(node)]
+	return price, ok
+}
+
+// teamOf finds the team from the pod's labels, then its annotations, then the namespace labels.
+func teamOf(pod podInfo, ns nameSpaceDetail, teamKey string) string {
+	if team, ok := pod.labels[teamKey]; ok {
+		return team
+	}
+	if team, ok := pod.annotations[teamKey]; ok {
+		return team
+	}
+	if team, ok := ns.labels[teamKey]; ok {
+		return team
+	}
+	return "unknown"
+}
+
+func addCost(lines map[string]*costLine, key string, pod podInfo, cost float64) {
+	if _, ok := lines[key]; !ok {
+		lines[key] = &costLine{name: key}
+	}
+	lines[key].pods++
+	lines[key].cpu += pod.reservedCPU
+	lines[key].ram += pod.reservedMemory
+	lines[key].monthly += cost
+}
+
+// allocateCosts splits every node's monthly price over the pods on it. A pod's share is the
+// average of its CPU and memory share of the node's allocatable; whatever is left is idle.
+func allocateCosts(cluster clusterDetail, pricing pricingTable, teamKey string) costReport {
+	report := costReport{
+		cluster:     cluster.name,
+		byNamespace: make(map[string]*costLine),
+		byWorkload:  make(map[string]*costLine),
+		byTeam:      make(map[string]*costLine),
+	}
+
+	type podRef struct {
+		pod podInfo
+		ns  nameSpaceDetail
+	}
+	var podsOnNode = make(map[string][]podRef)
+	for _, ns := range cluster.namespaces {
+		for _, pod := range ns.pods {
+			if pod.nodeName == "" || pod.Phase == v1.PodSucceeded || pod.Phase == v1.PodFailed {
+				continue
+			}
+			podsOnNode[pod.nodeName] = append(podsOnNode[pod.nodeName], podRef{pod: pod, ns: ns})
+		}
+	}
+
+	for _, node := range cluster.nodeList {
+		hourly, ok := pricing.nodeHourlyPrice(node)
+		if !ok {
+			report.unpriced = append(report.unpriced, node.Name)
+			continue
+		}
+		monthly := hourly * hoursPerMonth
+		allocCPU := node.Status.Allocatable.Cpu().MilliValue()
+		allocRAM := node.Status.Allocatable.Memory().Value()
+		report.total += monthly
+
+		allocated := 0.0
+		for _, ref := range podsOnNode[node.Name] {
+			share := (ratio(ref.pod.reservedCPU, allocCPU) + ratio(ref.pod.reservedMemory, allocRAM)) / 2
+			if allocCPU == 0 || allocRAM == 0 {
+				share = 0
+			}
+			cost := monthly * share
+			allocated += cost
+
+			workload := ref.pod.ownerName
+			if workload == "" {
+				workload = ref.pod.name
+			}
+			addCost(report.byNamespace, ref.ns.name, ref.pod, cost)
+			addCost(report.byWorkload, ref.ns.name+"/"+workload, ref.pod, cost)
+			addCost(report.byTeam, teamOf(ref.pod, ref.ns, teamKey), ref.pod, cost)
+		}
+		if allocated < monthly {
+			report.idle += monthly - allocated
+		}
+	}
+
+	return report
+}
+
+func sortedCostLines(lines map[string]*costLine) []*costLine {
+	var sorted []*costLine
+	for _, line := range lines {
+		sorted = append(sorted, line)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].monthly == sorted[j].monthly {
+			return sorted[i].name < sorted[j].name
+		}
+		return sorted[i].monthly > sorted[j].monthly
+	})
+	return sorted
+}
+
+func printCostReport(report costReport, teamKey string) {
+	var goodColor = colorString(32, false)
+	var warningColor = colorString(33, false)
+	var normalColor = colorString(37, false)
+	var darkGray = colorString(30, false)
+
+	sections := []struct {
+		title string
+		lines map[string]*costLine
+	}{
+		{"Cost by Team (" + teamKey + ")", report.byTeam},
+		{"Cost by Namespace", report.byNamespace},
+		{"Cost by Workload", report.byWorkload},
+	}
+
+	for _, section := range sections {
+		width := 0
+		for name := range section.lines {
+			if len(name) > width {
+				width = len(name)
+			}
+		}
+		fmt.Printf("\n%s===== %s%s%s =====%s\n", darkGray, goodColor, section.title, darkGray, normalColor)
+		for _, line := range sortedCostLines(section.lines) {
+			fmt.Printf("\t%*s: $%10.2f/mo  %4d pods, %6dm vCPU, %6d MB RAM\n", width, line.name, line.monthly, line.pods, line.cpu, line.ram/1024/1024)
+		}
+	}
+
+	fmt.Printf("\n Total node cost $%.2f/mo, of which $%.2f/mo is idle (not requested)\n", report.total, report.idle)
+	if len(report.unpriced) > 0 {
+		fmt.Printf(" %s%d nodes had no price and were left out: %s%s\n", warningColor, len(report.unpriced), printMap(report.unpriced), normalColor)
+	}
+	fmt.Println()
+}
+
+// costRow is one line of the showback as the reports and -cost-csv export it. Rollup is team,
+// namespace or workload, or idle for the cost of what nothing requests.
+type costRow struct {
+	Cluster     string  `json:"cluster"`
+	Rollup      string  `json:"rollup"`
+	Name        string  `json:"name,omitempty"`
+	Pods        int     `json:"pods"`
+	CPUMilli    int64   `json:"cpuRequestMilli"`
+	MemoryBytes int64   `json:"memoryRequestBytes"`
+	Monthly     float64 `json:"monthlyCost"`
+}
+
+// costRows lists all three rollups of every cluster, each followed by its idle cost.
+func costRows(reports []costReport) []costRow {
+	var rows []costRow
+	for _, report := range reports {
+		for _, rollup := range []struct {
+			name  string
+			lines map[string]*costLine
+		}{{"team", report.byTeam}, {"namespace", report.byNamespace}, {"workload", report.byWorkload}} {
+			for _, line := range sortedCostLines(rollup.lines) {
+				rows = append(rows, costRow{Cluster: report.cluster, Rollup: rollup.name, Name: line.name,
+					Pods: line.pods, CPUMilli: line.cpu, MemoryBytes: line.ram, Monthly: line.monthly})
+			}
+		}
+		rows = append(rows, costRow{Cluster: report.cluster, Rollup: "idle", Monthly: report.idle})
+	}
+	return rows
+}
+
+// writeCostCSV writes all three rollups of every cluster into one CSV.
+func writeCostCSV(path string, reports []costReport) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	w := csv.NewWriter(f)
+	w.Write([]string{"cluster", "rollup", "name", "pods", "cpu_millicores", "memory_bytes", "monthly_cost"})
+	for _, row := range costRows(reports) {
+		monthly := strconv.FormatFloat(row.Monthly, 'f', 2, 64)
+		if row.Rollup == "idle" {
+			w.Write([]string{row.Cluster, row.Rollup, "", "", "", "", monthly})
+			continue
+		}
+		w.Write([]string{row.Cluster, row.Rollup, row.Name, strconv.Itoa(row.Pods),
+			strconv.FormatInt(row.CPUMilli, 10), strconv.FormatInt(row.MemoryBytes, 10), monthly})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
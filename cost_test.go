@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCostsInEveryFormat(t *testing.T) {
+	costs := []costReport{{
+		cluster:     "prod",
+		byTeam:      map[string]*costLine{"payments": {name: "payments", pods: 2, cpu: 500, ram: 512 * 1024 * 1024, monthly: 73}},
+		byNamespace: map[string]*costLine{"web": {name: "web", pods: 2, cpu: 500, ram: 512 * 1024 * 1024, monthly: 73}},
+		byWorkload:  map[string]*costLine{"web/api": {name: "web/api", pods: 2, cpu: 500, ram: 512 * 1024 * 1024, monthly: 73}},
+		idle:        12.5,
+	}}
+
+	path := filepath.Join(t.TempDir(), "costs.csv")
+	if err := writeCostCSV(path, costs); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `cluster,rollup,name,pods,cpu_millicores,memory_bytes,monthly_cost
+prod,team,payments,2,500,536870912,73.00
+prod,namespace,web,2,500,536870912,73.00
+prod,workload,web/api,2,500,536870912,73.00
+prod,idle,,,,,12.50
+`
+	if string(data) != want {
+		t.Errorf("cost CSV\n%s\nwant\n%s", data, want)
+	}
+
+	var out bytes.Buffer
+	if err := (markdownRenderer{w: &out}).render(reportTables(nil, nil, nil, costs, ranking{})); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "Cost Showback") || !strings.Contains(out.String(), "| prod | workload | web/api | 2 | 500 | 512 | 73.00 |") {
+		t.Errorf("markdown report has no cost table:\n%s", out.String())
+	}
+	if tables := reportTables(nil, nil, nil, nil, ranking{}); tables[len(tables)-1].name == "costs" {
+		t.Error("cost table without -pricing")
+	}
+
+	out.Reset()
+	if err := writeJSONReport(&out, nil, costs, advisoryDB{}); err != nil {
+		t.Fatal(err)
+	}
+	var report struct {
+		Costs []costRow `json:"costs"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Costs) != 4 || report.Costs[3].Rollup != "idle" || report.Costs[3].Monthly != 12.5 {
+		t.Errorf("JSON costs %+v", report.Costs)
+	}
+
+	out.Reset()
+	if err := writeHTMLReport(&out, nil, nil, nil, costs, nil, ranking{}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "Cost Showback") || !strings.Contains(out.String(), `<td class="num">512</td>`) {
+		t.Error("HTML report has no cost table")
+	}
+}
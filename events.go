@@ -0,0 +1,256 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// clusterEvent is a core/v1 or events.k8s.io Event boiled down to what the timeline needs.
+type clusterEvent struct {
+	uid       string
+	namespace string
+	kind      string
+	name      string
+	reason    string
+	message   string
+	eventType string
+	count     int32
+	last      time.Time
+	workload  string
+}
+
+// coreEventTime is when a core/v1 Event last happened. Events recorded through events.k8s.io,
+// as current schedulers do, leave LastTimestamp empty and carry the time in their series or
+// EventTime instead.
+func coreEventTime(e v1.Event) time.Time {
+	switch {
+	case !e.LastTimestamp.IsZero():
+		return e.LastTimestamp.Time
+	case e.Series != nil && !e.Series.LastObservedTime.IsZero():
+		return e.Series.LastObservedTime.Time
+	}
+	return e.EventTime.Time
+}
+
+// collectEvents reads Events from both APIs; they are usually the same objects, so they are
+// deduplicated by UID. Only events seen since since, in namespaces the filter lets through, are kept.
+func collectEvents(c *kubernetes.Clientset, since time.Time, filter scanFilter) []clusterEvent {
+	var events []clusterEvent
+	var seen = make(map[string]bool)
+
+	coreEvents, _ := c.CoreV1().Events("").List(context.TODO(), filter.listOptions(""))
+	for _, e := range coreEvents.Items {
+		last := coreEventTime(e)
+		count := e.Count
+		if e.Series != nil {
+			count = e.Series.Count
+		}
+		if count == 0 {
+			count = 1
+		}
+		seen[string(e.UID)] = true
+		if last.Before(since) || !filter.includesNamespace(e.InvolvedObject.Namespace) {
+			continue
+		}
+		events = append(events, clusterEvent{
+			uid:       string(e.UID),
+			namespace: e.InvolvedObject.Namespace,
+			kind:      e.InvolvedObject.Kind,
+			name:      e.InvolvedObject.Name,
+			reason:    e.Reason,
+			message:   e.Message,
+			eventType: e.Type,
+			count:     count,
+			last:      last,
+		})
+	}
+
+	newEvents, err := c.EventsV1().Events("").List(context.TODO(), filter.listOptions(""))
+	if err != nil {
+		return events
+	}
+	for _, e := range newEvents.Items {
+		if seen[string(e.UID)] {
+			continue
+		}
+		last := e.EventTime.Time
+		count := e.DeprecatedCount
+		if e.Series != nil {
+			last = e.Series.LastObservedTime.Time
+			count = e.Series.Count
+		}
+		if last.IsZero() {
+			last = e.DeprecatedLastTimestamp.Time
+		}
+		if count == 0 {
+			count = 1
+		}
+		if last.Before(since) || !filter.includesNamespace(e.Regarding.Namespace) {
+			continue
+		}
+		events = append(events, clusterEvent{
+			uid:       string(e.UID),
+			namespace: e.Regarding.Namespace,
+			kind:      e.Regarding.Kind,
+			name:      e.Regarding.Name,
+			reason:    e.Reason,
+			message:   e.Note,
+			eventType: e.Type,
+			count:     count,
+			last:      last,
+		})
+	}
+
+	return events
+}
+
+// linkEventWorkloads fills in the workload of every event the same way the scanner resolves
+// owners: pods through their owner references, ReplicaSets through the pseudo owner.
+func linkEventWorkloads(events []clusterEvent, cluster clusterDetail) {
+	var podWorkloads = make(map[string]string)
+	for _, ns := range cluster.namespaces {
+		for _, pod := range ns.pods {
+			podWorkloads[ns.name+"/"+pod.name] = workloadOf(pod)
+		}
+	}
+
+	for i := range events {
+		switch events[i].kind {
+		case "Pod":
+			if workload, ok := podWorkloads[events[i].namespace+"/"+events[i].name]; ok {
+				events[i].workload = workload
+			} else {
+				events[i].workload = events[i].name
+			}
+		case "ReplicaSet":
+			if name, _ := findPseudoOwner(events[i].name, "ReplicaSet"); name != "" {
+				events[i].workload = name
+			} else {
+				events[i].workload = events[i].name
+			}
+		default:
+			events[i].workload = events[i].name
+		}
+	}
+}
+
+func printEventTimeline(cluster clusterDetail, events []clusterEvent, window time.Duration, buckets int) {
+	var goodColor = colorString(32, false)
+	var errorColor = colorString(31, false)
+	var warningColor = colorString(33, false)
+	var normalColor = colorString(37, false)
+	var darkGray = colorString(30, false)
+
+	now := time.Now()
+	start := now.Add(-window)
+	bucketSize := window / time.Duration(buckets)
+	var timeline = make([]int32, buckets)
+	var normal int32
+
+	type group struct {
+		reason  string
+		kind    string
+		count   int32
+		objects map[string]bool
+	}
+	type workloadGroup struct {
+		namespace string
+		workload  string
+		reason    string
+		count     int32
+		last      time.Time
+		message   string
+	}
+	var groups = make(map[string]*group)
+	var workloads = make(map[string]*workloadGroup)
+
+	for _, e := range events {
+		if e.eventType != "Warning" {
+			normal += e.count
+			continue
+		}
+		bucket := int(e.last.Sub(start) / bucketSize)
+		if bucket >= buckets {
+			bucket = buckets - 1
+		}
+		if bucket >= 0 {
+			timeline[bucket] += e.count
+		}
+
+		key := e.reason + "/" + e.kind
+		if _, ok := groups[key]; !ok {
+			groups[key] = &group{reason: e.reason, kind: e.kind, objects: make(map[string]bool)}
+		}
+		groups[key].count += e.count
+		groups[key].objects[e.namespace+"/"+e.name] = true
+
+		// Deduplicate the same warning on the same workload, summing the counts.
+		wkey := e.namespace + "/" + e.workload + "/" + e.reason
+		if _, ok := workloads[wkey]; !ok {
+			workloads[wkey] = &workloadGroup{namespace: e.namespace, workload: e.workload, reason: e.reason}
+		}
+		workloads[wkey].count += e.count
+		if e.last.After(workloads[wkey].last) {
+			workloads[wkey].last = e.last
+			workloads[wkey].message = e.message
+		}
+	}
+
+	fmt.Printf("\n%s===== %sWarning Events in %s, last %s%s =====%s\n", darkGray, errorColor, cluster.name, window, darkGray, normalColor)
+	var max int32
+	for _, count := range timeline {
+		if count > max {
+			max = count
+		}
+	}
+	for i, count := range timeline {
+		width := 0
+		if max > 0 {
+			width = int(count * 40 / max)
+		}
+		color := goodColor
+		if count > 0 {
+			color = warningColor
+		}
+		fmt.Printf("\t%s %s|%s%-40s%s|%s %d\n", start.Add(time.Duration(i)*bucketSize).Format("15:04"), darkGray, color, strings.Repeat("█", width), darkGray, normalColor, count)
+	}
+	fmt.Printf("\t%d Normal events not shown\n", normal)
+
+	var sortedGroups []*group
+	for _, g := range groups {
+		sortedGroups = append(sortedGroups, g)
+	}
+	sort.Slice(sortedGroups, func(i, j int) bool {
+		if sortedGroups[i].count == sortedGroups[j].count {
+			return sortedGroups[i].reason+sortedGroups[i].kind < sortedGroups[j].reason+sortedGroups[j].kind
+		}
+		return sortedGroups[i].count > sortedGroups[j].count
+	})
+	fmt.Printf("\n%s===== %sWarnings by Reason%s =====%s\n", darkGray, goodColor, darkGray, normalColor)
+	for _, g := range sortedGroups {
+		fmt.Printf("\t%s%6d%s %-32s %-24s on %d objects\n", errorColor, g.count, normalColor, g.reason, g.kind, len(g.objects))
+	}
+
+	var sortedWorkloads []*workloadGroup
+	for _, w := range workloads {
+		sortedWorkloads = append(sortedWorkloads, w)
+	}
+	sort.Slice(sortedWorkloads, func(i, j int) bool {
+		if sortedWorkloads[i].count == sortedWorkloads[j].count {
+			return sortedWorkloads[i].namespace+sortedWorkloads[i].workload < sortedWorkloads[j].namespace+sortedWorkloads[j].workload
+		}
+		return sortedWorkloads[i].count > sortedWorkloads[j].count
+	})
+	fmt.Printf("\n%s===== %sWarnings by Workload%s =====%s\n", darkGray, goodColor, darkGray, normalColor)
+	for _, w := range sortedWorkloads {
+		fmt.Printf("\t%s%6d%s %s%s/%s%s %s, %s ago\n", errorColor, w.count, normalColor, darkGray, w.namespace, normalColor, w.workload, w.reason, secDiff(int64(now.Sub(w.last).Seconds())))
+		fmt.Printf("\t       %s%s%s\n", darkGray, w.message, normalColor)
+	}
+	fmt.Println()
+}
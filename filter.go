@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// maxNamespaceScopedLists is how many matched namespaces are listed one by one before a scan
+// falls back to listing the whole cluster and filtering here.
+const maxNamespaceScopedLists = 10
+
+// systemNamespaces are skipped by -exclude-system-namespaces.
+var systemNamespaces = []string{"kube-system", "kube-public", "kube-node-lease"}
+
+// stringList is a flag that can be repeated and also takes comma separated values.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			*l = append(*l, v)
+		}
+	}
+	return nil
+}
+
+// namespacePattern is a glob (team-*) or, between slashes, a regular expression (/^team-(a|b)$/).
+type namespacePattern struct {
+	text   string
+	regexp *regexp.Regexp
+}
+
+func newNamespacePattern(text string) (namespacePattern, error) {
+	pattern := namespacePattern{text: text}
+	if len(text) > 1 && strings.HasPrefix(text, "/") && strings.HasSuffix(text, "/") {
+		re, err := regexp.Compile(text[1 : len(text)-1])
+		if err != nil {
+			return pattern, fmt.Errorf("namespace pattern %s: %v", text, err)
+		}
+		pattern.regexp = re
+	} else if _, err := path.Match(text, ""); err != nil {
+		return pattern, fmt.Errorf("namespace pattern %s: %v", text, err)
+	}
+	return pattern, nil
+}
+
+// literal is true when the pattern can only ever match the namespace of the same name.
+func (p namespacePattern) literal() bool {
+	return p.regexp == nil && !strings.ContainsAny(p.text, "*?[\\")
+}
+
+func (p namespacePattern) match(namespace string) bool {
+	if p.regexp != nil {
+		return p.regexp.MatchString(namespace)
+	}
+	matched, _ := path.Match(p.text, namespace)
+	return matched
+}
+
+// scanFilter limits what a scan reads: which namespaces, which pods and which nodes. Label
+// selectors and literal namespace exclusions are sent to the API server, patterns are
+// matched here.
+type scanFilter struct {
+	namespaces        []namespacePattern
+	excludeNamespaces []namespacePattern
+	selector          string
+	nodeSelector      string
+}
+
+func newScanFilter(namespaces, excludeNamespaces []string, excludeSystem bool, selector, nodeSelector string) (scanFilter, error) {
+	var filter scanFilter
+	if excludeSystem {
+		excludeNamespaces = append(excludeNamespaces, systemNamespaces...)
+	}
+	for _, text := range namespaces {
+		pattern, err := newNamespacePattern(text)
+		if err != nil {
+			return filter, err
+		}
+		filter.namespaces = append(filter.namespaces, pattern)
+	}
+	for _, text := range excludeNamespaces {
+		pattern, err := newNamespacePattern(text)
+		if err != nil {
+			return filter, err
+		}
+		filter.excludeNamespaces = append(filter.excludeNamespaces, pattern)
+	}
+	if _, err := labels.Parse(selector); err != nil {
+		return filter, fmt.Errorf("selector: %v", err)
+	}
+	if _, err := labels.Parse(nodeSelector); err != nil {
+		return filter, fmt.Errorf("node selector: %v", err)
+	}
+	filter.selector = selector
+	filter.nodeSelector = nodeSelector
+	return filter, nil
+}
+
+// limited is true when the filter leaves anything out.
+func (f scanFilter) limited() bool {
+	return len(f.namespaces) > 0 || len(f.excludeNamespaces) > 0 || f.selector != "" || f.nodeSelector != ""
+}
+
+// includesNamespace reports whether a namespace passes the include and exclude patterns.
+func (f scanFilter) includesNamespace(namespace string) bool {
+	for _, pattern := range f.excludeNamespaces {
+		if pattern.match(namespace) {
+			return false
+		}
+	}
+	if len(f.namespaces) == 0 {
+		return true
+	}
+	for _, pattern := range f.namespaces {
+		if pattern.match(namespace) {
+			return true
+		}
+	}
+	return false
+}
+
+// namespaceFieldSelector excludes the literal exclusions on the server.
+func (f scanFilter) namespaceFieldSelector() string {
+	var selectors []string
+	for _, pattern := range f.excludeNamespaces {
+		if pattern.literal() {
+			selectors = append(selectors, "metadata.namespace!="+pattern.text)
+		}
+	}
+	return strings.Join(selectors, ",")
+}
+
+// listOptions are the options for listing namespaced objects, with fieldSelector added to the
+// namespace exclusions.
+func (f scanFilter) listOptions(fieldSelector string) metav1.ListOptions {
+	var selectors []string
+	for _, selector := range []string{fieldSelector, f.namespaceFieldSelector()} {
+		if selector != "" {
+			selectors = append(selectors, selector)
+		}
+	}
+	return metav1.ListOptions{FieldSelector: strings.Join(selectors, ",")}
+}
+
+// podListOptions also carry the pod selector.
+func (f scanFilter) podListOptions() metav1.ListOptions {
+	options := f.listOptions("")
+	options.LabelSelector = f.selector
+	return options
+}
+
+func (f scanFilter) nodeListOptions() metav1.ListOptions {
+	return metav1.ListOptions{LabelSelector: f.nodeSelector}
+}
+
+// scopes are the namespaces to list objects in: the matched namespaces one by one when there are
+// only a few of them, otherwise "" for the whole cluster.
+func (f scanFilter) scopes(matched []string) []string {
+	if len(f.namespaces) == 0 || len(matched) > maxNamespaceScopedLists {
+		return []string{""}
+	}
+	return matched
+}
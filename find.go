@@ -0,0 +1,210 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+type findHit struct {
+	cluster   string
+	namespace string
+	workload  string
+	pod       string
+	node      string
+	detail    string
+}
+
+// podReferences lists the ConfigMaps and Secrets a pod uses through volumes, env, envFrom and
+// imagePullSecrets.
+func podReferences(pod v1.Pod) ([]string, []string) {
+	var configMaps, secrets []string
+	addConfigMap := func(name string) {
+		if name != "" && !contains(configMaps, name) {
+			configMaps = append(configMaps, name)
+		}
+	}
+	addSecret := func(name string) {
+		if name != "" && !contains(secrets, name) {
+			secrets = append(secrets, name)
+		}
+	}
+
+	for _, volume := range pod.Spec.Volumes {
+		if volume.ConfigMap != nil {
+			addConfigMap(volume.ConfigMap.Name)
+		}
+		if volume.Secret != nil {
+			addSecret(volume.Secret.SecretName)
+		}
+		if volume.Projected != nil {
+			for _, source := range volume.Projected.Sources {
+				if source.ConfigMap != nil {
+					addConfigMap(source.ConfigMap.Name)
+				}
+				if source.Secret != nil {
+					addSecret(source.Secret.Name)
+				}
+			}
+		}
+	}
+
+	containers := append([]v1.Container{}, pod.Spec.InitContainers...)
+	for _, container := range append(containers, pod.Spec.Containers...) {
+		for _, envFrom := range container.EnvFrom {
+			if envFrom.ConfigMapRef != nil {
+				addConfigMap(envFrom.ConfigMapRef.Name)
+			}
+			if envFrom.SecretRef != nil {
+				addSecret(envFrom.SecretRef.Name)
+			}
+		}
+		for _, env := range container.Env {
+			if env.ValueFrom == nil {
+				continue
+			}
+			if env.ValueFrom.ConfigMapKeyRef != nil {
+				addConfigMap(env.ValueFrom.ConfigMapKeyRef.Name)
+			}
+			if env.ValueFrom.SecretKeyRef != nil {
+				addSecret(env.ValueFrom.SecretKeyRef.Name)
+			}
+		}
+	}
+
+	for _, pullSecret := range pod.Spec.ImagePullSecrets {
+		addSecret(pullSecret.Name)
+	}
+
+	return configMaps, secrets
+}
+
+// matchImage matches a digest (sha256:...) against spec and running digests, anything else as a
+// substring of the image reference.
+func matchImage(container containerInfo, query string) bool {
+	if strings.HasPrefix(query, "sha256:") {
+		return strings.HasPrefix(container.runningDigest, query) || strings.HasPrefix(container.ref.digest, query)
+	}
+	return strings.Contains(container.image, query) || strings.Contains(container.ref.String(), query)
+}
+
+// checkFindArgs validates `find <kind> <value>` so a mistake fails before the clusters are scanned.
+func checkFindArgs(args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: %s [flags] find image|label|owner|ref <value>", os.Args[0])
+	}
+	switch args[0] {
+	case "image", "owner", "ref":
+	case "label":
+		if _, err := labels.Parse(args[1]); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("can not find by %q, use image, label, owner or ref", args[0])
+	}
+	return nil
+}
+
+// findPods searches the scanned clusters. kind is one of image, label, owner or ref.
+func findPods(clusters []clusterDetail, kind string, query string) ([]findHit, error) {
+	var hits []findHit
+	var selector labels.Selector
+
+	if err := checkFindArgs([]string{kind, query}); err != nil {
+		return nil, err
+	}
+	if kind == "label" {
+		selector, _ = labels.Parse(query)
+	}
+
+	for _, cluster := range clusters {
+		for _, ns := range cluster.namespaces {
+			for _, pod := range ns.pods {
+				hit := findHit{
+					cluster:   cluster.name,
+					namespace: ns.name,
+					workload:  workloadOf(pod),
+					pod:       pod.name,
+					node:      pod.nodeName,
+				}
+				switch kind {
+				case "image":
+					for _, container := range pod.containers {
+						if matchImage(container, query) {
+							hit.detail = container.name + " " + container.image
+							hits = append(hits, hit)
+						}
+					}
+				case "label":
+					if selector.Matches(labels.Set(pod.labels)) {
+						hits = append(hits, hit)
+					}
+				case "owner":
+					if strings.Contains(pod.ownerName, query) {
+						hit.detail = pod.ownerKind
+						hits = append(hits, hit)
+					}
+				case "ref":
+					if contains(pod.configMapRefs, query) {
+						hit.detail = "ConfigMap " + query
+						hits = append(hits, hit)
+					}
+					if contains(pod.secretRefs, query) {
+						hit.detail = "Secret " + query
+						hits = append(hits, hit)
+					}
+				}
+			}
+		}
+	}
+
+	sort.SliceStable(hits, func(i, j int) bool {
+		a := []string{hits[i].cluster, hits[i].namespace, hits[i].workload, hits[i].pod}
+		b := []string{hits[j].cluster, hits[j].namespace, hits[j].workload, hits[j].pod}
+		return strings.Join(a, "/") < strings.Join(b, "/")
+	})
+	return hits, nil
+}
+
+// runFind handles `kube-helper find image|label|owner|ref <value>`.
+func runFind(clusters []clusterDetail, args []string) {
+	var goodColor = colorString(32, false)
+	var errorColor = colorString(31, false)
+	var normalColor = colorString(37, false)
+	var darkGray = colorString(30, false)
+
+	hits, err := findPods(clusters, args[0], args[1])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s%s%s\n", errorColor, err.Error(), normalColor)
+		os.Exit(2)
+	}
+
+	// A pod can match more than once, e.g. by two containers running the image.
+	var pods = make(map[string]bool)
+	var widths [5]int
+	for _, hit := range hits {
+		pods[hit.cluster+"/"+hit.namespace+"/"+hit.pod] = true
+		for i, value := range []string{hit.cluster, hit.namespace, hit.workload, hit.pod, hit.node} {
+			if len(value) > widths[i] {
+				widths[i] = len(value)
+			}
+		}
+	}
+
+	fmt.Printf("\n%s===== %sFound %d pods (%d matches) by %s %q%s =====%s\n", darkGray, goodColor, len(pods), len(hits), args[0], args[1], darkGray, normalColor)
+	for _, hit := range hits {
+		fmt.Printf(" %-*s %-*s %-*s %-*s %s%-*s%s %s\n",
+			widths[0], hit.cluster,
+			widths[1], hit.namespace,
+			widths[2], hit.workload,
+			widths[3], hit.pod,
+			darkGray, widths[4], hit.node, normalColor,
+			hit.detail,
+		)
+	}
+	fmt.Println()
+}
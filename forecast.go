@@ -0,0 +1,240 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"time"
+)
+
+// forecastHorizon is how far ahead an exhaustion date is still worth printing.
+const forecastHorizon = 5 * 365 * 24 * time.Hour
+
+// capacitySample is one scan of a node group, or of a whole cluster: what its nodes can hold
+// and what is requested of them.
+type capacitySample struct {
+	at        time.Time
+	nodes     int64
+	allocCPU  int64
+	allocRAM  int64
+	allocPods int64
+	cpu       int64
+	memory    int64
+	pods      int64
+}
+
+// capacitySeries is every sample of a node group or cluster, oldest first.
+type capacitySeries struct {
+	name    string
+	samples []capacitySample
+}
+
+func (s capacitySeries) last() capacitySample { return s.samples[len(s.samples)-1] }
+
+// loadCapacity reads the node group samples of one cluster since a point in time. The cluster
+// series sums every group of each scan.
+func loadCapacity(db *sql.DB, cluster string, since time.Time) (capacitySeries, []capacitySeries, error) {
+	rows, err := db.Query(`
+		SELECT s.scanned_at, g.node_group, g.nodes, g.cpu_allocatable_milli, g.memory_allocatable_bytes,
+			g.pod_capacity, g.cpu_request_milli, g.memory_request_bytes, g.pods
+		FROM scans s JOIN node_group_samples g ON g.scan_id = s.id
+		WHERE s.cluster = ? AND s.scanned_at >= ?
+		ORDER BY s.scanned_at, s.id`, cluster, since.Unix())
+	if err != nil {
+		return capacitySeries{}, nil, err
+	}
+	defer rows.Close()
+
+	total := capacitySeries{name: cluster}
+	var groups = make(map[string]*capacitySeries)
+	for rows.Next() {
+		var at int64
+		var name string
+		var sample capacitySample
+		if err := rows.Scan(&at, &name, &sample.nodes, &sample.allocCPU, &sample.allocRAM,
+			&sample.allocPods, &sample.cpu, &sample.memory, &sample.pods); err != nil {
+			return total, nil, err
+		}
+		sample.at = time.Unix(at, 0)
+		if _, ok := groups[name]; !ok {
+			groups[name] = &capacitySeries{name: name}
+		}
+		groups[name].samples = append(groups[name].samples, sample)
+
+		if n := len(total.samples); n == 0 || !total.samples[n-1].at.Equal(sample.at) {
+			total.samples = append(total.samples, capacitySample{at: sample.at})
+		}
+		current := &total.samples[len(total.samples)-1]
+		current.nodes += sample.nodes
+		current.allocCPU += sample.allocCPU
+		current.allocRAM += sample.allocRAM
+		current.allocPods += sample.allocPods
+		current.cpu += sample.cpu
+		current.memory += sample.memory
+		current.pods += sample.pods
+	}
+
+	var series []capacitySeries
+	for _, s := range groups {
+		series = append(series, *s)
+	}
+	return total, series, rows.Err()
+}
+
+// fitTrend is a least squares line through the samples, as the change per second and the value
+// at the time of the first sample. ok is false when there are fewer than two points in time.
+func fitTrend(samples []capacitySample, value func(capacitySample) int64) (slope, intercept float64, ok bool) {
+	if len(samples) < 2 || !samples[len(samples)-1].at.After(samples[0].at) {
+		return 0, 0, false
+	}
+	start := samples[0].at
+	var sumX, sumY, sumXX, sumXY float64
+	for _, sample := range samples {
+		x := sample.at.Sub(start).Seconds()
+		y := float64(value(sample))
+		sumX += x
+		sumY += y
+		sumXX += x * x
+		sumXY += x * y
+	}
+	n := float64(len(samples))
+	slope = (n*sumXY - sumX*sumY) / (n*sumXX - sumX*sumX)
+	intercept = (sumY - slope*sumX) / n
+	return slope, intercept, true
+}
+
+// exhaustion estimates when the requests of a series reach its current capacity. The date is
+// zero when the requests are not growing, the last scan when they already reached it, and just
+// past the forecastHorizon from the last scan when it is further away than that.
+func exhaustion(series capacitySeries, requested, capacity func(capacitySample) int64) (time.Time, float64, bool) {
+	last := series.last()
+	slope, intercept, ok := fitTrend(series.samples, requested)
+	if !ok {
+		return time.Time{}, 0, false
+	}
+	perMonth := slope * 30 * 24 * 3600
+	if requested(last) >= capacity(last) {
+		return last.at, perMonth, true
+	}
+	if slope <= 0 {
+		return time.Time{}, perMonth, true
+	}
+	// Slow growth puts the crossing centuries away, past what a Duration holds, so anything
+	// beyond the horizon is reported as such before it is converted.
+	seconds := (float64(capacity(last)) - intercept) / slope
+	if seconds-last.at.Sub(series.samples[0].at).Seconds() > forecastHorizon.Seconds() {
+		return last.at.Add(forecastHorizon + time.Second), perMonth, true
+	}
+	at := series.samples[0].at.Add(time.Duration(seconds * float64(time.Second)))
+	if at.Before(last.at) {
+		at = last.at
+	}
+	return at, perMonth, true
+}
+
+// forecastCell is one resource of a forecast row: how full it is now, how fast that changes a
+// month, and when it runs out. Red runs out within a quarter, yellow within a year.
+func forecastCell(series capacitySeries, requested, capacity func(capacitySample) int64, now time.Time) string {
+	var darkGray = colorString(30, false)
+	var normalColor = colorString(37, false)
+	last := series.last()
+	if capacity(last) == 0 {
+		return fmt.Sprintf("%s%-28s%s", darkGray, "no capacity", normalColor)
+	}
+	used := float64(requested(last)) / float64(capacity(last)) * 100
+	at, perMonth, ok := exhaustion(series, requested, capacity)
+	if !ok {
+		return fmt.Sprintf("%4.0f%% %s%-22s%s", used, darkGray, "one scan only", normalColor)
+	}
+	monthly := perMonth / float64(capacity(last)) * 100
+
+	color, date := 32, "not growing"
+	switch {
+	case at.IsZero():
+	case !at.After(last.at):
+		color, date = 31, "exhausted"
+	case at.Sub(last.at) > forecastHorizon:
+		date = "> 5 years"
+	default:
+		date = at.Format("2006-01-02")
+		if at.Sub(now) < 90*24*time.Hour {
+			color = 31
+		} else if at.Sub(now) < 365*24*time.Hour {
+			color = 33
+		}
+	}
+	return fmt.Sprintf("%4.0f%% %s%+6.1f%%/mo%s %s%-11s%s", used, darkGray, monthly, normalColor, colorString(color, false), date, normalColor)
+}
+
+// printForecast prints one row per series for requested CPU, requested memory and pods against
+// allocatable CPU, allocatable memory and max pods.
+func printForecast(series capacitySeries, nameWidth int, now time.Time) {
+	last := series.last()
+	fmt.Printf(" %-*s %5d  %s  %s  %s\n", nameWidth, series.name, last.nodes,
+		forecastCell(series, func(s capacitySample) int64 { return s.cpu }, func(s capacitySample) int64 { return s.allocCPU }, now),
+		forecastCell(series, func(s capacitySample) int64 { return s.memory }, func(s capacitySample) int64 { return s.allocRAM }, now),
+		forecastCell(series, func(s capacitySample) int64 { return s.pods }, func(s capacitySample) int64 { return s.allocPods }, now))
+}
+
+// runForecast fits the history of the last days of every cluster and node group and prints when
+// their current nodes run out of CPU, memory or pods. clusters limits the output to those
+// contexts when it is not empty.
+func runForecast(path string, clusters []string, days int, rank ranking) error {
+	var goodColor = colorString(32, false)
+	var normalColor = colorString(37, false)
+	var darkGray = colorString(30, false)
+
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("no history at %s yet, run a scan first", path)
+	}
+	db, err := openHistory(path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if len(clusters) == 0 {
+		if clusters, err = historyClusters(db); err != nil {
+			return err
+		}
+	}
+	now := time.Now()
+	since := now.AddDate(0, 0, -days)
+	for _, cluster := range clusters {
+		total, groups, err := loadCapacity(db, cluster, since)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("\n%s===== %sForecast for %s, from the last %d days%s =====%s\n", darkGray, goodColor, cluster, days, darkGray, normalColor)
+		if len(total.samples) == 0 {
+			fmt.Printf(" No node group samples of %s in the last %d days\n", cluster, days)
+			continue
+		}
+		fmt.Printf(" %d scans from %s to %s, at the node counts of the last scan\n\n", len(total.samples),
+			total.samples[0].at.Format("2006-01-02 15:04"), total.last().at.Format("2006-01-02 15:04"))
+
+		nameWidth := len(cluster)
+		if len(settings.NodeGroupKey) > nameWidth {
+			nameWidth = len(settings.NodeGroupKey)
+		}
+		var items []rankItem
+		var byName = make(map[string]capacitySeries)
+		for _, s := range groups {
+			if len(s.name) > nameWidth {
+				nameWidth = len(s.name)
+			}
+			last := s.last()
+			items = append(items, rankItem{key: s.name, cpu: last.cpu, memory: last.memory, pods: int(last.pods),
+				age: int64(now.Sub(s.samples[0].at).Seconds())})
+			byName[s.name] = s
+		}
+		fmt.Printf(" %s%-*s %5s  %-28s  %-28s  %-28s%s\n", darkGray, nameWidth, settings.NodeGroupKey, "Nodes",
+			"CPU requested", "RAM requested", "Pods", normalColor)
+		printForecast(total, nameWidth, now)
+		fmt.Println()
+		for _, name := range rank.rank(items) {
+			printForecast(byName[name], nameWidth, now)
+		}
+	}
+	return nil
+}
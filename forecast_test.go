@@ -0,0 +1,69 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// cpuSeries is a node group with allocatable CPU whose requests take the given values one day
+// apart.
+func cpuSeries(start time.Time, allocatable int64, requests ...int64) capacitySeries {
+	series := capacitySeries{name: "workers"}
+	for i, cpu := range requests {
+		series.samples = append(series.samples, capacitySample{at: start.AddDate(0, 0, i), nodes: 1, allocCPU: allocatable, cpu: cpu})
+	}
+	return series
+}
+
+func requestedCPU(s capacitySample) int64   { return s.cpu }
+func allocatableCPU(s capacitySample) int64 { return s.allocCPU }
+
+func TestExhaustion(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	last := start.AddDate(0, 0, 2)
+	tests := []struct {
+		name        string
+		allocatable int64
+		requests    []int64
+		want        time.Time
+		beyond      bool
+	}{
+		{"growing", 1000, []int64{400, 500, 600}, start.AddDate(0, 0, 6), false},
+		{"flat", 1000, []int64{500, 500, 500}, time.Time{}, false},
+		{"shrinking", 1000, []int64{600, 500, 400}, time.Time{}, false},
+		{"already full", 1000, []int64{800, 900, 1000}, last, false},
+		// Half a millicore a day towards 10000 cores is tens of thousands of years away, more
+		// than a Duration holds.
+		{"barely growing", 10000000, []int64{500, 500, 501}, time.Time{}, true},
+	}
+	for _, test := range tests {
+		at, _, ok := exhaustion(cpuSeries(start, test.allocatable, test.requests...), requestedCPU, allocatableCPU)
+		if !ok {
+			t.Errorf("%s: no forecast", test.name)
+			continue
+		}
+		if test.beyond {
+			if at.Sub(last) <= forecastHorizon {
+				t.Errorf("%s: runs out at %s, want past the horizon", test.name, at)
+			}
+			continue
+		}
+		if !at.Equal(test.want) {
+			t.Errorf("%s: runs out at %s, want %s", test.name, at, test.want)
+		}
+	}
+
+	if _, _, ok := exhaustion(cpuSeries(start, 1000, 500), requestedCPU, allocatableCPU); ok {
+		t.Error("a single sample gave a forecast")
+	}
+}
+
+func TestForecastCellBeyondHorizon(t *testing.T) {
+	output = plainRenderer{}
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	cell := forecastCell(cpuSeries(start, 10000000, 500, 500, 501), requestedCPU, allocatableCPU, start.AddDate(0, 0, 3))
+	if !strings.Contains(cell, "> 5 years") || strings.Contains(cell, "exhausted") {
+		t.Errorf("a barely growing group shows %q, want > 5 years", cell)
+	}
+}
@@ -0,0 +1,385 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// historySchema is one row per scan of a cluster, with the aggregates of that scan hanging off it.
+const historySchema = `
+CREATE TABLE IF NOT EXISTS scans (
+	id         INTEGER PRIMARY KEY,
+	cluster    TEXT    NOT NULL,
+	scanned_at INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS scans_cluster ON scans (cluster, scanned_at);
+CREATE TABLE IF NOT EXISTS namespace_samples (
+	scan_id              INTEGER NOT NULL REFERENCES scans (id),
+	namespace            TEXT    NOT NULL,
+	cpu_request_milli    INTEGER NOT NULL,
+	memory_request_bytes INTEGER NOT NULL,
+	pods                 INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS pod_status_samples (
+	scan_id   INTEGER NOT NULL REFERENCES scans (id),
+	namespace TEXT    NOT NULL,
+	status    TEXT    NOT NULL,
+	pods      INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS node_samples (
+	scan_id       INTEGER NOT NULL REFERENCES scans (id),
+	instance_type TEXT    NOT NULL,
+	nodes         INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS image_samples (
+	scan_id    INTEGER NOT NULL REFERENCES scans (id),
+	image      TEXT    NOT NULL,
+	containers INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS node_group_samples (
+	scan_id                  INTEGER NOT NULL REFERENCES scans (id),
+	node_group               TEXT    NOT NULL,
+	nodes                    INTEGER NOT NULL,
+	cpu_allocatable_milli    INTEGER NOT NULL,
+	memory_allocatable_bytes INTEGER NOT NULL,
+	pod_capacity             INTEGER NOT NULL,
+	cpu_request_milli        INTEGER NOT NULL,
+	memory_request_bytes     INTEGER NOT NULL,
+	pods                     INTEGER NOT NULL
+);
+`
+
+// sparkBlocks are the eight heights a sparkline is drawn with.
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// defaultHistoryPath is $XDG_DATA_HOME/kube-helper/history.db, or ~/.local/share/kube-helper/history.db.
+func defaultHistoryPath() string {
+	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" {
+		return filepath.Join(dir, "kube-helper", "history.db")
+	}
+	if home := homeDir(); home != "" {
+		return filepath.Join(home, ".local", "share", "kube-helper", "history.db")
+	}
+	return ""
+}
+
+func openHistory(path string) (*sql.DB, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(historySchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return db, nil
+}
+
+// recordHistory appends the aggregates of every scanned cluster to the history file, in one
+// transaction so a trend never sees half a scan.
+func recordHistory(path string, clusters []clusterDetail, scanned time.Time) error {
+	db, err := openHistory(path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	for _, cluster := range clusters {
+		if err := recordCluster(tx, cluster, scanned); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func recordCluster(tx *sql.Tx, cluster clusterDetail, scanned time.Time) error {
+	result, err := tx.Exec(`INSERT INTO scans (cluster, scanned_at) VALUES (?, ?)`, cluster.name, scanned.Unix())
+	if err != nil {
+		return err
+	}
+	scanID, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	var images = make(map[string]imageInfo)
+	for _, ns := range cluster.namespaces {
+		if _, err := tx.Exec(`INSERT INTO namespace_samples VALUES (?, ?, ?, ?, ?)`,
+			scanID, ns.name, ns.totalCPURequest, ns.totalRAMRequest, len(ns.pods)); err != nil {
+			return err
+		}
+		for status, count := range statusMap(ns.statusSummary) {
+			if _, err := tx.Exec(`INSERT INTO pod_status_samples VALUES (?, ?, ?, ?)`, scanID, ns.name, status, count); err != nil {
+				return err
+			}
+		}
+		for _, info := range ns.images {
+			mergeImage(images, info)
+		}
+	}
+
+	_, types := summarizeNodes(cluster)
+	for _, info := range types {
+		if _, err := tx.Exec(`INSERT INTO node_samples VALUES (?, ?, ?)`, scanID, info.Name, info.Count); err != nil {
+			return err
+		}
+	}
+	for key, info := range images {
+		if _, err := tx.Exec(`INSERT INTO image_samples VALUES (?, ?, ?)`, scanID, key, info.count); err != nil {
+			return err
+		}
+	}
+	for _, group := range groupNodeUsage(cluster) {
+		cpu, memory := group.daemonCPU, group.daemonRAM
+		for _, pod := range group.pods {
+			cpu += pod.reservedCPU
+			memory += pod.reservedMemory
+		}
+		if _, err := tx.Exec(`INSERT INTO node_group_samples VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			scanID, group.name, group.nodes, group.allocCPU, group.allocRAM, group.allocPods,
+			cpu, memory, len(group.pods)+group.daemonPods); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// trendSample is one scan's totals for a namespace, or for a whole cluster.
+type trendSample struct {
+	at     time.Time
+	cpu    int64
+	memory int64
+	pods   int64
+}
+
+// trendSeries is every sample of a namespace or cluster, oldest first.
+type trendSeries struct {
+	name    string
+	samples []trendSample
+}
+
+func (s trendSeries) first() trendSample { return s.samples[0] }
+func (s trendSeries) last() trendSample  { return s.samples[len(s.samples)-1] }
+
+// loadTrends reads the namespace samples of one cluster since a point in time. The cluster
+// series sums every namespace of each scan, and a namespace missing from a scan counts as empty
+// in it.
+func loadTrends(db *sql.DB, cluster string, since time.Time) (trendSeries, []trendSeries, error) {
+	rows, err := db.Query(`
+		SELECT s.scanned_at, n.namespace, n.cpu_request_milli, n.memory_request_bytes, n.pods
+		FROM scans s JOIN namespace_samples n ON n.scan_id = s.id
+		WHERE s.cluster = ? AND s.scanned_at >= ?
+		ORDER BY s.scanned_at, s.id`, cluster, since.Unix())
+	if err != nil {
+		return trendSeries{}, nil, err
+	}
+	defer rows.Close()
+
+	total := trendSeries{name: cluster}
+	var namespaces = make(map[string]*trendSeries)
+	for rows.Next() {
+		var at int64
+		var name string
+		var sample trendSample
+		if err := rows.Scan(&at, &name, &sample.cpu, &sample.memory, &sample.pods); err != nil {
+			return total, nil, err
+		}
+		sample.at = time.Unix(at, 0)
+		if _, ok := namespaces[name]; !ok {
+			namespaces[name] = &trendSeries{name: name}
+		}
+		namespaces[name].samples = append(namespaces[name].samples, sample)
+
+		if n := len(total.samples); n == 0 || !total.samples[n-1].at.Equal(sample.at) {
+			total.samples = append(total.samples, trendSample{at: sample.at})
+		}
+		current := &total.samples[len(total.samples)-1]
+		current.cpu += sample.cpu
+		current.memory += sample.memory
+		current.pods += sample.pods
+	}
+
+	var series []trendSeries
+	for _, s := range namespaces {
+		filled := trendSeries{name: s.name}
+		next := 0
+		for _, scan := range total.samples {
+			if next < len(s.samples) && s.samples[next].at.Equal(scan.at) {
+				filled.samples = append(filled.samples, s.samples[next])
+				next++
+			} else {
+				filled.samples = append(filled.samples, trendSample{at: scan.at})
+			}
+		}
+		series = append(series, filled)
+	}
+	return total, series, rows.Err()
+}
+
+// historyClusters lists every cluster the history has scans of.
+func historyClusters(db *sql.DB) ([]string, error) {
+	rows, err := db.Query(`SELECT DISTINCT cluster FROM scans ORDER BY cluster`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var clusters []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		clusters = append(clusters, name)
+	}
+	return clusters, rows.Err()
+}
+
+// resample spreads the samples over width columns between since and now. Each column holds
+// the last sample taken before its end, so gaps between scans repeat the previous value and
+// columns before the first scan are NaN.
+func resample(samples []trendSample, value func(trendSample) float64, since, now time.Time, width int) []float64 {
+	var points = make([]float64, width)
+	step := now.Sub(since) / time.Duration(width)
+	next := 0
+	current := math.NaN()
+	for i := range points {
+		end := since.Add(step * time.Duration(i+1))
+		for next < len(samples) && !samples[next].at.After(end) {
+			current = value(samples[next])
+			next++
+		}
+		points[i] = current
+	}
+	return points
+}
+
+// sparkline draws points from lowest to highest of the range they cover, colored like barChart
+// from green (low) through yellow to red (high). Missing points stay blank.
+func sparkline(points []float64) string {
+	low, high := math.Inf(1), math.Inf(-1)
+	for _, p := range points {
+		if !math.IsNaN(p) {
+			low = math.Min(low, p)
+			high = math.Max(high, p)
+		}
+	}
+	var line strings.Builder
+	for _, p := range points {
+		if math.IsNaN(p) {
+			line.WriteString(" ")
+			continue
+		}
+		level := 0
+		if high > low {
+			level = int((p - low) / (high - low) * float64(len(sparkBlocks)-1))
+		}
+		color := 32
+		if level >= 6 {
+			color = 31
+		} else if level >= 3 {
+			color = 33
+		}
+		line.WriteString(colorString(color, false) + string(sparkBlocks[level]))
+	}
+	line.WriteString(colorString(37, false))
+	return line.String()
+}
+
+// growth is the change from the first to the last sample as a signed percentage.
+func growth(first, last int64) string {
+	if first == 0 {
+		if last == 0 {
+			return "    0%"
+		}
+		return "   new"
+	}
+	return fmt.Sprintf("%+5.0f%%", float64(last-first)/float64(first)*100)
+}
+
+// printTrend draws one row per series for the requested CPU, requested memory and pod count.
+func printTrend(series trendSeries, nameWidth, width int, since, now time.Time) {
+	var darkGray = colorString(30, false)
+	var normalColor = colorString(37, false)
+	first, last := series.first(), series.last()
+	cpu := resample(series.samples, func(s trendSample) float64 { return float64(s.cpu) }, since, now, width)
+	memory := resample(series.samples, func(s trendSample) float64 { return float64(s.memory) }, since, now, width)
+	pods := resample(series.samples, func(s trendSample) float64 { return float64(s.pods) }, since, now, width)
+	fmt.Printf(" %-*s %s %s%6dm %s%s %s %s%6dMB %s%s %s %s%5d %s%s\n",
+		nameWidth, series.name,
+		sparkline(cpu), darkGray, last.cpu, growth(first.cpu, last.cpu), normalColor,
+		sparkline(memory), darkGray, last.memory/1024/1024, growth(first.memory, last.memory), normalColor,
+		sparkline(pods), darkGray, last.pods, growth(first.pods, last.pods), normalColor)
+}
+
+// runTrend prints how every cluster, and the namespaces in it, grew over the last days.
+// clusters limits the output to those contexts when it is not empty.
+func runTrend(path string, clusters []string, days int, width int, rank ranking) error {
+	var goodColor = colorString(32, false)
+	var normalColor = colorString(37, false)
+	var darkGray = colorString(30, false)
+
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("no history at %s yet, run a scan first", path)
+	}
+	db, err := openHistory(path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if len(clusters) == 0 {
+		if clusters, err = historyClusters(db); err != nil {
+			return err
+		}
+	}
+	now := time.Now()
+	since := now.AddDate(0, 0, -days)
+	for _, cluster := range clusters {
+		total, namespaces, err := loadTrends(db, cluster, since)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("\n%s===== %sTrend for %s, last %d days%s =====%s\n", darkGray, goodColor, cluster, days, darkGray, normalColor)
+		if len(total.samples) == 0 {
+			fmt.Printf(" No scans of %s in the last %d days\n", cluster, days)
+			continue
+		}
+		fmt.Printf(" %d scans from %s to %s\n\n", len(total.samples), total.first().at.Format("2006-01-02 15:04"), total.last().at.Format("2006-01-02 15:04"))
+
+		nameWidth := len(cluster)
+		var items []rankItem
+		var byName = make(map[string]trendSeries)
+		for _, s := range namespaces {
+			if len(s.name) > nameWidth {
+				nameWidth = len(s.name)
+			}
+			last := s.last()
+			items = append(items, rankItem{key: s.name, cpu: last.cpu, memory: last.memory, pods: int(last.pods),
+				age: int64(now.Sub(s.first().at).Seconds())})
+			byName[s.name] = s
+		}
+		columnWidth := width + 15
+		fmt.Printf(" %s%-*s %-*s %-*s %-*s%s\n", darkGray, nameWidth, "", columnWidth, "CPU requested", columnWidth+1, "RAM requested", columnWidth, "Pods", normalColor)
+		printTrend(total, nameWidth, width, since, now)
+		fmt.Println()
+		for _, name := range rank.rank(items) {
+			printTrend(byName[name], nameWidth, width, since, now)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,372 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+	"time"
+)
+
+// zoneColors mirror the terminal's zone coloring, where zone a is ANSI 33 (yellow), b is 34
+// (blue) and so on.
+var zoneColors = []string{"#c4a000", "#3465a4", "#75507b", "#06989a", "#d3d7cf"}
+
+// chartColors mirror barChart, which starts each stacked bar at ANSI 31 (red).
+var chartColors = []string{"#cc0000", "#4e9a06", "#c4a000", "#3465a4", "#75507b", "#06989a", "#d3d7cf"}
+
+type htmlSegment struct {
+	Label   string
+	Value   float64
+	Percent float64
+	Color   string
+}
+
+// htmlChart is what barChart prints in the terminal: one stacked bar with a total.
+type htmlChart struct {
+	Title    string
+	Segments []htmlSegment
+	Total    float64
+	Suffix   string
+}
+
+type htmlPod struct {
+	Status      string
+	StatusClass string
+	Name        string
+	CPU         int64
+	MemoryMB    int64
+	Heavy       bool
+	Pending     string
+}
+
+type htmlNamespace struct {
+	Name            string
+	VirtualServices int
+	ConfigMaps      int
+	Secrets         int
+	Images          int
+	CPU             int64
+	MemoryMB        int64
+	BadHelmSecrets  []string
+	Pods            []htmlPod
+}
+
+type htmlNode struct {
+	nodeSummary
+	GroupColor string
+	ZoneColor  string
+	OnDemand   bool
+}
+
+type htmlCount struct {
+	Label string
+	Count int
+	Class string
+}
+
+type htmlCluster struct {
+	Name            string
+	Namespaces      int
+	EmptyNamespaces []string
+	Statuses        []htmlCount
+	Charts          []htmlChart
+	NamespaceList   []htmlNamespace
+	WorkloadTypes   []htmlCount
+	InstanceTypes   []instanceTypeSummary
+	Nodes           []htmlNode
+}
+
+type htmlDeployment struct {
+	Name     string
+	Count    int
+	CPU      int64
+	MemoryMB int64
+}
+
+type htmlImage struct {
+	Count      int
+	Registry   string
+	Repository string
+	Version    string
+	Vulns      string
+}
+
+type htmlReport struct {
+	Generated   string
+	Clusters    []htmlCluster
+	Deployments []htmlDeployment
+	Images      []htmlImage
+	Costs       []costRow
+}
+
+func statusClass(status string) string {
+	switch status {
+	case "Running", "Completed":
+		return "good"
+	case "Pending", "Failed", "Unknown", "CrashLoop":
+		return "error"
+	}
+	return "warning"
+}
+
+func rgbHex(c rgb) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.red, c.green, c.blue)
+}
+
+// newChart turns labeled values into a stacked bar, largest first, folding everything past the
+// first few into "other" so the bar stays readable.
+func newChart(title string, values map[string]float64, suffix string) htmlChart {
+	chart := htmlChart{Title: title, Suffix: suffix}
+	var labels []string
+	for label, value := range values {
+		chart.Total += value
+		if value > 0 {
+			labels = append(labels, label)
+		}
+	}
+	sort.Slice(labels, func(i, j int) bool {
+		if values[labels[i]] != values[labels[j]] {
+			return values[labels[i]] > values[labels[j]]
+		}
+		return labels[i] < labels[j]
+	})
+	if chart.Total == 0 {
+		return chart
+	}
+	var other float64
+	for i, label := range labels {
+		if i >= len(chartColors)-1 {
+			other += values[label]
+			continue
+		}
+		chart.Segments = append(chart.Segments, htmlSegment{Label: label, Value: values[label], Percent: 100 * values[label] / chart.Total, Color: chartColors[i]})
+	}
+	if other > 0 {
+		chart.Segments = append(chart.Segments, htmlSegment{Label: "other", Value: other, Percent: 100 * other / chart.Total, Color: chartColors[len(chartColors)-1]})
+	}
+	return chart
+}
+
+func buildHTMLCluster(cluster clusterDetail, tagColors []rgb, rank ranking) htmlCluster {
+	result := htmlCluster{Name: cluster.name, Namespaces: len(cluster.namespaces)}
+
+	var statuses podStatusSummary
+	var cpuByNamespace = make(map[string]float64)
+	var ramByNamespace = make(map[string]float64)
+	var details = make(map[string]htmlNamespace)
+
+	for _, name := range sortedNamespaceNames(cluster.namespaces) {
+		ns := cluster.namespaces[name]
+		if len(ns.pods)+len(ns.virtualServices)+len(ns.ingresses)+len(ns.configMaps)+len(ns.secrets)+len(ns.cronJobs) == 0 {
+			result.EmptyNamespaces = append(result.EmptyNamespaces, name)
+		}
+		statuses = statuses.plus(ns.statusSummary)
+		cpuByNamespace[name] = float64(ns.totalCPURequest) / 1000
+		ramByNamespace[name] = float64(ns.totalRAMRequest) / 1024 / 1024 / 1024
+		if len(ns.pods) == 0 {
+			continue
+		}
+
+		detail := htmlNamespace{
+			Name:            name,
+			VirtualServices: len(ns.virtualServices),
+			ConfigMaps:      len(ns.configMaps),
+			Secrets:         len(ns.secrets),
+			Images:          len(ns.images),
+			CPU:             ns.totalCPURequest,
+			MemoryMB:        ns.totalRAMRequest / 1024 / 1024,
+		}
+		for _, secret := range ns.secrets {
+			if helmPending(secret) {
+				detail.BadHelmSecrets = append(detail.BadHelmSecrets, secret.data.GetName()+": "+settings.HelmPendingLabel)
+			}
+		}
+		for _, pod := range rank.sortPods(ns.pods) {
+			p := htmlPod{
+				Status:      pod.status,
+				StatusClass: statusClass(pod.status),
+				Name:        pod.name,
+				CPU:         pod.reservedCPU,
+				MemoryMB:    pod.reservedMemory / 1024 / 1024,
+				Heavy:       heavyPod(pod),
+			}
+			if pod.status == "Pending" {
+				p.Pending = pod.scheduling.reason + " " + pod.scheduling.message
+			}
+			detail.Pods = append(detail.Pods, p)
+		}
+		details[name] = detail
+	}
+	for _, name := range rank.namespaces(cluster, true) {
+		result.NamespaceList = append(result.NamespaceList, details[name])
+	}
+
+	for i, count := range statuses.counts() {
+		result.Statuses = append(result.Statuses, htmlCount{Label: podStatuses[i], Count: count, Class: statusClass(podStatuses[i])})
+	}
+	result.Statuses = append(result.Statuses, htmlCount{Label: "CrashLoop", Count: statuses.crashlooping, Class: "error"})
+	for i := range result.Statuses {
+		if result.Statuses[i].Count == 0 {
+			result.Statuses[i].Class = "muted"
+		}
+	}
+
+	result.Charts = append(result.Charts, newChart("CPU requested by namespace", cpuByNamespace, " vCPU"))
+	result.Charts = append(result.Charts, newChart("RAM requested by namespace", ramByNamespace, " GiB"))
+
+	// Nodes, colored like the terminal Node Breakdown.
+	var workloadTypes []string
+	var cpuByGroup = make(map[string]float64)
+	for _, node := range cluster.nodeList {
+		if group := nodeGroupOf(node); group != "default" && !contains(workloadTypes, group) {
+			workloadTypes = append(workloadTypes, group)
+		}
+		cores, _ := node.Status.Capacity.Cpu().AsInt64()
+		cpuByGroup[nodeGroupOf(node)] += float64(cores)
+	}
+	sort.Strings(workloadTypes)
+	for i, workloadType := range workloadTypes {
+		result.WorkloadTypes = append(result.WorkloadTypes, htmlCount{Label: workloadType, Class: rgbHex(tagColors[i%len(tagColors)])})
+	}
+
+	nodes, instanceTypes := summarizeNodes(cluster)
+	result.InstanceTypes = rank.instanceTypeSummaries(instanceTypes)
+	for _, summary := range rank.nodeSummaries(cluster, nodes) {
+		n := htmlNode{nodeSummary: summary, GroupColor: "#c0c0c0", ZoneColor: "#d3d7cf", OnDemand: summary.CapacityType == "ON_DEMAND"}
+		if c, ok := inSlice(workloadTypes, n.Group); ok {
+			n.GroupColor = rgbHex(tagColors[c%len(tagColors)])
+		}
+		if n.Zone != "" {
+			if c := int(n.Zone[len(n.Zone)-1]) - int('a'); c >= 0 && c < len(zoneColors) {
+				n.ZoneColor = zoneColors[c]
+			}
+		}
+		result.Nodes = append(result.Nodes, n)
+	}
+	if len(cluster.nodeList) > 0 {
+		result.Charts = append(result.Charts, newChart("Cores by node group", cpuByGroup, " cores"))
+	}
+	return result
+}
+
+// writeHTMLReport renders the scan as one self-contained HTML page: no external CSS, scripts or
+// images, so it can be published as a single file.
+func writeHTMLReport(w io.Writer, clusters []clusterDetail, deployments map[string]deployInfo, images map[string]imageInfo, costs []costReport, tagColors []rgb, rank ranking) error {
+	report := htmlReport{Generated: time.Now().Format(time.RFC1123), Costs: costRows(costs)}
+	for _, cluster := range clusters {
+		report.Clusters = append(report.Clusters, buildHTMLCluster(cluster, tagColors, rank))
+	}
+	for _, name := range rank.workloads(clusters, deployments) {
+		info := deployments[name]
+		report.Deployments = append(report.Deployments, htmlDeployment{Name: info.name, Count: info.count, CPU: info.totalCPURequest, MemoryMB: info.totalRAMRequest / 1024 / 1024})
+	}
+	for _, key := range rank.images(images) {
+		info := images[key]
+		image := htmlImage{Count: info.count, Registry: info.imageRepo, Repository: info.imageName, Version: info.imageVersion}
+		if info.scanned {
+			image.Vulns = vulnSummary(info.vulns)
+		}
+		report.Images = append(report.Images, image)
+	}
+
+	page := template.Must(template.New("report").Funcs(template.FuncMap{
+		"css": func(s string) template.CSS { return template.CSS(s) },
+		"mb":  func(bytes int64) int64 { return bytes / 1024 / 1024 },
+	}).Parse(htmlTemplate))
+	return page.Execute(w, report)
+}
+
+const htmlTemplate = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>kube-helper report</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 2em; color: #222; }
+h1, h2, h3 { font-weight: 500; }
+h2 { border-bottom: 2px solid #4e9a06; padding-bottom: .2em; }
+table { border-collapse: collapse; margin: .5em 0 1.5em; font-size: 13px; }
+th, td { padding: 3px 10px; text-align: left; }
+th { background: #eee; }
+table.sortable th { cursor: pointer; user-select: none; }
+table.sortable th:after { content: " ⇅"; color: #aaa; }
+tr:nth-child(even) td { background: #f7f7f7; }
+td.num { text-align: right; font-variant-numeric: tabular-nums; }
+.good { color: #4e9a06; } .warning { color: #c4a000; } .error { color: #cc0000; } .muted { color: #999; }
+.statuses span { margin-right: 1.2em; }
+details { margin: .3em 0; } summary { cursor: pointer; }
+.chart { margin: .6em 0; } .bar { display: flex; height: 18px; width: 640px; border: 1px solid #888; }
+.bar div { height: 100%; } .legend span { margin-right: 1em; font-size: 12px; }
+.swatch { display: inline-block; width: .8em; height: .8em; border-radius: 50%; margin-right: .3em; }
+.taint { color: #888; font-size: 12px; }
+</style>
+</head>
+<body>
+<h1>kube-helper report</h1>
+<p class="muted">Generated {{.Generated}}</p>
+{{range .Clusters}}
+<h2>Cluster {{.Name}}</h2>
+<p>There are {{.Namespaces}} namespaces, {{len .EmptyNamespaces}} of which are empty{{if .EmptyNamespaces}}: <span class="warning">{{range $i, $n := .EmptyNamespaces}}{{if $i}}, {{end}}{{$n}}{{end}}</span>{{end}}.</p>
+<p class="statuses">{{range .Statuses}}<span class="{{.Class}}">{{.Count}} {{.Label}}</span>{{end}}</p>
+{{range .Charts}}
+<div class="chart"><div>{{.Title}}: {{printf "%.2f" .Total}}{{.Suffix}}</div>
+<div class="bar">{{range .Segments}}<div style="{{css (printf "width:%.2f%%;background:%s" .Percent .Color)}}" title="{{.Label}}: {{printf "%.2f" .Value}}"></div>{{end}}</div>
+<div class="legend">{{range .Segments}}<span><span class="swatch" style="{{css (printf "background:%s" .Color)}}"></span>{{.Label}} {{printf "%.2f" .Value}}</span>{{end}}</div></div>
+{{end}}
+<h3>Namespaces</h3>
+{{range .NamespaceList}}
+<details><summary><b>{{.Name}}</b> has {{.VirtualServices}} vs, {{.ConfigMaps}} cm, {{.Secrets}} secrets, and {{len .Pods}} pods using {{.Images}} images with requests of {{.CPU}}m CPU &amp; {{.MemoryMB}} MB RAM</summary>
+{{range .BadHelmSecrets}}<div class="error">Bad Helm Secret {{.}}</div>{{end}}
+<table class="sortable"><thead><tr><th>Status</th><th>Pod</th><th>CPU (m)</th><th>Memory (MB)</th></tr></thead><tbody>
+{{range .Pods}}<tr><td class="{{.StatusClass}}">{{.Status}}</td><td>{{.Name}}{{if .Pending}}<div class="warning">{{.Pending}}</div>{{end}}</td><td class="num">{{.CPU}}</td><td class="num{{if .Heavy}} warning{{end}}">{{.MemoryMB}}</td></tr>
+{{end}}</tbody></table>
+</details>
+{{end}}
+{{if .Nodes}}
+<h3>Instance Type Breakdown</h3>
+<table class="sortable"><thead><tr><th>Count</th><th>Instance type</th><th>vCPU</th><th>RAM (GiB)</th><th>Local storage</th></tr></thead><tbody>
+{{range .InstanceTypes}}<tr><td class="num">{{.Count}}</td><td>{{.Name}}</td><td class="num">{{.VCPU}}</td><td class="num">{{.MemoryGiB}}</td><td class="num">{{.Storage}}</td></tr>
+{{end}}</tbody></table>
+<h3>Node Breakdown</h3>
+<p>{{range .WorkloadTypes}}<span><span class="swatch" style="{{css (printf "background:%s" .Class)}}"></span>{{.Label}}</span> {{end}}</p>
+<table class="sortable"><thead><tr><th></th><th>Node</th><th>Group</th><th>Age</th><th>Capacity</th><th>Zone</th><th>CPUs</th><th>RAM (GiB)</th><th>Instance type</th><th>IP</th><th>Pods</th><th>Req vCPU</th><th>Req GiB</th></tr></thead><tbody>
+{{range .Nodes}}<tr><td>{{.Warnings}}</td><td><span class="swatch" style="{{css (printf "background:%s" .GroupColor)}}"></span>{{.Name}}{{range .Taints}}<div class="taint">Taint {{.}}</div>{{end}}</td><td>{{.Group}}</td><td>{{.Age}}</td><td>{{if .OnDemand}}<b>{{.CapacityType}}</b>{{else}}{{.CapacityType}}{{end}}</td><td style="{{css (printf "color:%s" .ZoneColor)}}">{{.Zone}}</td><td class="num">{{.CPUs}}</td><td class="num">{{.MemoryGiB}}</td><td>{{.InstanceType}}</td><td>{{.IP}}</td><td class="num">{{.Pods}}</td><td class="num">{{.RequestCPU}}</td><td class="num">{{.RequestGiB}}</td></tr>
+{{end}}</tbody></table>
+{{end}}
+{{end}}
+<h2>Deployment Breakdown</h2>
+<table class="sortable"><thead><tr><th>Pods</th><th>Workload</th><th>CPU (m)</th><th>RAM (MB)</th></tr></thead><tbody>
+{{range .Deployments}}<tr><td class="num">{{.Count}}</td><td>{{.Name}}</td><td class="num">{{.CPU}}</td><td class="num">{{.MemoryMB}}</td></tr>
+{{end}}</tbody></table>
+<h2>Image Breakdown</h2>
+<table class="sortable"><thead><tr><th>Containers</th><th>Registry</th><th>Repository</th><th>Version</th><th>Vulnerabilities</th></tr></thead><tbody>
+{{range .Images}}<tr><td class="num">{{.Count}}</td><td>{{.Registry}}</td><td>{{.Repository}}</td><td>{{.Version}}</td><td>{{.Vulns}}</td></tr>
+{{end}}</tbody></table>
+{{if .Costs}}<h2>Cost Showback</h2>
+<table class="sortable"><thead><tr><th>Cluster</th><th>Rollup</th><th>Name</th><th>Pods</th><th>CPU (m)</th><th>RAM (MB)</th><th>$/month</th></tr></thead><tbody>
+{{range .Costs}}{{if eq .Rollup "idle"}}<tr><td>{{.Cluster}}</td><td>idle</td><td></td><td></td><td></td><td></td><td class="num">{{printf "%.2f" .Monthly}}</td></tr>
+{{else}}<tr><td>{{.Cluster}}</td><td>{{.Rollup}}</td><td>{{.Name}}</td><td class="num">{{.Pods}}</td><td class="num">{{.CPUMilli}}</td><td class="num">{{mb .MemoryBytes}}</td><td class="num">{{printf "%.2f" .Monthly}}</td></tr>
+{{end}}{{end}}</tbody></table>
+{{end}}<script>
+document.querySelectorAll("table.sortable").forEach(function (table) {
+  table.querySelectorAll("th").forEach(function (th, column) {
+    var ascending = true;
+    th.addEventListener("click", function () {
+      var body = table.tBodies[0];
+      var rows = Array.prototype.slice.call(body.rows);
+      rows.sort(function (a, b) {
+        var x = a.cells[column].innerText, y = b.cells[column].innerText;
+        var nx = parseFloat(x), ny = parseFloat(y);
+        var order = (!isNaN(nx) && !isNaN(ny)) ? nx - ny : x.localeCompare(y);
+        return ascending ? order : -order;
+      });
+      ascending = !ascending;
+      rows.forEach(function (row) { body.appendChild(row); });
+    });
+  });
+});
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	checkLatestTag  = "latest-tag"
+	checkMutableTag = "mutable-tag"
+	checkRegistry   = "registry"
+	checkPullAlways = "pull-always"
+)
+
+type imageFinding struct {
+	namespace string
+	workload  string
+	container string
+	image     string
+	check     string
+	detail    string
+}
+
+// imageSizes maps every name a node knows an image by (tags and digests) to its size in bytes.
+func imageSizes(nodes []v1.Node) map[string]int64 {
+	var sizes = make(map[string]int64)
+	for _, node := range nodes {
+		for _, image := range node.Status.Images {
+			for _, name := range image.Names {
+				if image.SizeBytes > sizes[name] {
+					sizes[name] = image.SizeBytes
+				}
+			}
+		}
+	}
+	return sizes
+}
+
+// imageSize finds a container's image in the node image list, by running digest first.
+func imageSize(sizes map[string]int64, container containerInfo) int64 {
+	if container.runningDigest != "" {
+		if size, ok := sizes[container.ref.registry+"/"+container.ref.repository+"@"+container.runningDigest]; ok {
+			return size
+		}
+	}
+	if size, ok := sizes[container.ref.String()]; ok {
+		return size
+	}
+	return sizes[container.image]
+}
+
+type tagKey struct {
+	workload  string
+	container string
+	image     string
+}
+
+func workloadOf(pod podInfo) string {
+	if pod.ownerName != "" {
+		return pod.ownerName
+	}
+	return pod.name
+}
+
+// checkImageHygiene looks at every container in the cluster. allowedRegistries may be empty, in
+// which case any registry is fine; largeImage is in bytes.
+func checkImageHygiene(cluster clusterDetail, allowedRegistries []string, largeImage int64) []imageFinding {
+	var findings []imageFinding
+	var sizes = imageSizes(cluster.nodeList)
+
+	for _, ns := range cluster.namespaces {
+		// running digests seen per tag, to find tags that moved under us.
+		var digests = make(map[tagKey][]string)
+		var reported = make(map[string]bool)
+
+		for _, pod := range ns.pods {
+			workload := workloadOf(pod)
+			for _, container := range pod.containers {
+				add := func(check string, detail string) {
+					key := strings.Join([]string{workload, container.name, container.image, check}, "/")
+					if reported[key] {
+						return
+					}
+					reported[key] = true
+					findings = append(findings, imageFinding{
+						namespace: ns.name,
+						workload:  workload,
+						container: container.name,
+						image:     container.image,
+						check:     check,
+						detail:    detail,
+					})
+				}
+
+				if container.ref.digest == "" && (container.ref.tag == "" || container.ref.tag == "latest") {
+					add(checkLatestTag, "image is not pinned to a version")
+				}
+
+				if len(allowedRegistries) > 0 && !contains(allowedRegistries, container.ref.registry) {
+					add(checkRegistry, fmt.Sprintf("pulled from %s", container.ref.registry))
+				}
+
+				if container.pullPolicy == v1.PullAlways {
+					if size := imageSize(sizes, container); size >= largeImage {
+						add(checkPullAlways, fmt.Sprintf("imagePullPolicy Always on a %d MB image", size/1024/1024))
+					}
+				}
+
+				if container.ref.digest == "" && container.runningDigest != "" {
+					key := tagKey{workload: workload, container: container.name, image: container.image}
+					if !contains(digests[key], container.runningDigest) {
+						digests[key] = append(digests[key], container.runningDigest)
+					}
+				}
+			}
+		}
+
+		for key, seen := range digests {
+			if len(seen) > 1 {
+				sort.Strings(seen)
+				findings = append(findings, imageFinding{
+					namespace: ns.name,
+					workload:  key.workload,
+					container: key.container,
+					image:     key.image,
+					check:     checkMutableTag,
+					detail:    "same tag is running different digests: " + strings.Join(seen, ", "),
+				})
+			}
+		}
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].namespace != findings[j].namespace {
+			return findings[i].namespace < findings[j].namespace
+		}
+		if findings[i].workload != findings[j].workload {
+			return findings[i].workload < findings[j].workload
+		}
+		if findings[i].container != findings[j].container {
+			return findings[i].container < findings[j].container
+		}
+		return findings[i].check < findings[j].check
+	})
+	return findings
+}
+
+// printImageHygiene lists findings per namespace and workload, or only per namespace when
+// byWorkload is off.
+func printImageHygiene(findings []imageFinding, byWorkload bool) {
+	var goodColor = colorString(32, false)
+	var errorColor = colorString(31, false)
+	var warningColor = colorString(33, false)
+	var normalColor = colorString(37, false)
+	var darkGray = colorString(30, false)
+
+	var counts = make(map[string]int)
+	for _, finding := range findings {
+		counts[finding.check]++
+	}
+
+	fmt.Printf("\n%s===== %sImage Hygiene%s =====%s\n", darkGray, goodColor, darkGray, normalColor)
+	fmt.Printf(" %d unpinned/latest, %d mutable tags, %d disallowed registries, %d large images pulled Always\n",
+		counts[checkLatestTag], counts[checkMutableTag], counts[checkRegistry], counts[checkPullAlways])
+
+	lastNamespace, lastWorkload := "", ""
+	for _, finding := range findings {
+		if finding.namespace != lastNamespace {
+			fmt.Printf("\n %sNamespace %s%s\n", normalColor, finding.namespace, normalColor)
+			lastNamespace = finding.namespace
+			lastWorkload = ""
+		}
+		if byWorkload && finding.workload != lastWorkload {
+			fmt.Printf("   %s%s\n", finding.workload, normalColor)
+			lastWorkload = finding.workload
+		}
+		color := warningColor
+		if finding.check == checkRegistry || finding.check == checkMutableTag {
+			color = errorColor
+		}
+		container := finding.container
+		if !byWorkload {
+			container = finding.workload + "/" + finding.container
+		}
+		fmt.Printf("     %s%-12s%s %s (%s): %s\n", color, finding.check, normalColor, container, finding.image, finding.detail)
+	}
+	fmt.Println()
+}
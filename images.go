@@ -0,0 +1,168 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/distribution/reference"
+	v1 "k8s.io/api/core/v1"
+)
+
+// imageRef is a container image reference split into its parts. Docker Hub short names are
+// normalized, so nginx:1.25 becomes docker.io / library/nginx / 1.25.
+type imageRef struct {
+	registry   string
+	repository string
+	tag        string
+	digest     string
+}
+
+type containerInfo struct {
+	name          string
+	image         string
+	ref           imageRef
+	runningDigest string
+	init          bool
+	pullPolicy    v1.PullPolicy
+}
+
+func parseImageRef(image string) (imageRef, error) {
+	var ref imageRef
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return ref, err
+	}
+	ref.registry = reference.Domain(named)
+	ref.repository = reference.Path(named)
+	if tagged, ok := named.(reference.Tagged); ok {
+		ref.tag = tagged.Tag()
+	}
+	if digested, ok := named.(reference.Digested); ok {
+		ref.digest = digested.Digest().String()
+	}
+	return ref, nil
+}
+
+// String puts the reference back together the long way, e.g. docker.io/library/nginx:1.25.
+func (r imageRef) String() string {
+	s := r.registry + "/" + r.repository
+	if r.tag != "" {
+		s += ":" + r.tag
+	}
+	if r.digest != "" {
+		s += "@" + r.digest
+	}
+	return s
+}
+
+// key is what the image breakdowns group by: the normalized reference with the tag the runtime
+// pulls, so nginx, nginx:latest and docker.io/library/nginx:latest are one image. References
+// that did not parse keep their raw name.
+func (r imageRef) key() string {
+	if r.registry == "" {
+		return r.repository
+	}
+	key := r.registry + "/" + r.repository
+	if r.tag != "" || r.digest == "" {
+		key += ":" + r.version()
+	}
+	if r.digest != "" {
+		key += "@" + r.digest
+	}
+	return key
+}
+
+// version is the tag, the digest when there is no tag, or "latest" which is what the runtime pulls.
+func (r imageRef) version() string {
+	if r.tag != "" {
+		return r.tag
+	}
+	if r.digest != "" {
+		return r.digest
+	}
+	return "latest"
+}
+
+// digestFromImageID pulls the digest out of ContainerStatuses[].ImageID, which depending on the
+// runtime looks like docker-pullable://repo@sha256:..., repo@sha256:... or sha256:...
+func digestFromImageID(imageID string) string {
+	if i := strings.LastIndex(imageID, "@"); i >= 0 {
+		return imageID[i+1:]
+	}
+	if i := strings.Index(imageID, "://"); i >= 0 {
+		imageID = imageID[i+3:]
+	}
+	if strings.HasPrefix(imageID, "sha256:") {
+		return imageID
+	}
+	return ""
+}
+
+// collectContainers walks the init and regular containers of a pod, records their images in
+// images, and returns the summed requests of the regular containers.
+func collectContainers(pod v1.Pod, images map[string]imageInfo) (int64, int64, []containerInfo) {
+	var cpuRequests, memoryRequests int64
+	var containers []containerInfo
+
+	runningDigests := make(map[string]string)
+	statuses := append([]v1.ContainerStatus{}, pod.Status.InitContainerStatuses...)
+	for _, status := range append(statuses, pod.Status.ContainerStatuses...) {
+		runningDigests[status.Name] = digestFromImageID(status.ImageID)
+	}
+
+	specs := append([]v1.Container{}, pod.Spec.InitContainers...)
+	specs = append(specs, pod.Spec.Containers...)
+	for i, container := range specs {
+		isInit := i < len(pod.Spec.InitContainers)
+		if !isInit {
+			memoryRequests += container.Resources.Requests.Memory().Value()
+			cpuRequests += container.Resources.Requests.Cpu().MilliValue()
+		}
+
+		ref, err := parseImageRef(container.Image)
+		if err != nil {
+			// Keep it in the report under its raw name rather than dropping it.
+			ref = imageRef{repository: container.Image}
+		}
+		info := containerInfo{
+			name:          container.Name,
+			image:         container.Image,
+			ref:           ref,
+			runningDigest: runningDigests[container.Name],
+			init:          isInit,
+			pullPolicy:    container.ImagePullPolicy,
+		}
+		containers = append(containers, info)
+
+		key := ref.key()
+		image := images[key]
+		image.count++
+		image.imageKey = key
+		image.imageRepo = ref.registry
+		image.imageName = ref.repository
+		image.imageVersion = ref.version()
+		image.imageDigest = ref.digest
+		if info.runningDigest != "" && !contains(image.runningDigests, info.runningDigest) {
+			image.runningDigests = append(image.runningDigests, info.runningDigest)
+		}
+		images[key] = image
+	}
+
+	return cpuRequests, memoryRequests, containers
+}
+
+// mergeImage folds one namespace's view of an image into the cluster wide imageMap.
+func mergeImage(imageMap map[string]imageInfo, info imageInfo) {
+	merged, ok := imageMap[info.imageKey]
+	if !ok {
+		merged = info
+		merged.count = 0
+		merged.runningDigests = nil
+	}
+	merged.count += info.count
+	for _, digest := range info.runningDigests {
+		if !contains(merged.runningDigests, digest) {
+			merged.runningDigests = append(merged.runningDigests, digest)
+		}
+	}
+	imageMap[info.imageKey] = merged
+}
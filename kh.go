@@ -4,6 +4,7 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -12,12 +13,10 @@ import (
 	"github.com/schollz/progressbar/v3"
 
 	v1 "k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/tools/clientcmd"
 
 	//
 	// Uncomment to load all auth plugins
@@ -31,14 +30,7 @@ import (
 )
 
 func colorString(code int, bold bool) string {
-	var makeBold int
-	if bold {
-		makeBold = 1
-	} else {
-		makeBold = 0
-	}
-
-	return fmt.Sprintf("\033[%d;%d;49m", makeBold, code)
+	return output.color(code, bold)
 }
 
 func secDiff(s int64) string {
@@ -98,41 +90,31 @@ func checkDeprecations(c *kubernetes.Clientset, n string) bool {
 	return deprecations
 }
 
-// for x := 0; x < len(pods.Items[i].Status.ContainerStatuses); x++ {
-// 	if pods.Items[i].Status.ContainerStatuses[x].RestartCount >= restartLimit && (printPodDetails || printPodDetailsWide) { //&&
-// 		//						time.Now().Sub(pods.Items[i].Status.StartTime.Time).Minutes() < lastRestartWarningTime {
-// 		fmt.Printf("%s RESTART WARNING: %s in the %s namespace restarted a total of %d times in the last %s! %s %s %s\n",
-// 			warningColor,
-// 			pods.Items[i].Name,
-// 			namespaces.Items[n].Name,
-// 			pods.Items[i].Status.ContainerStatuses[x].RestartCount,
-// 			secDiff(int64(time.Now().Unix()-pods.Items[i].Status.StartTime.Unix())),
-// 			pods.Items[i].Status.Conditions[0].Reason,
-// 			pods.Items[i].Status.Conditions[0].Message,
-// 			normalColor,
-// 		)
-// 	}
-// }
-
 type clusterDetail struct {
 	name          string
 	namespaces    map[string]nameSpaceDetail
 	nodes         []nodeInstanceType
+	nodeList      []v1.Node
 	version       string
 	clientset     kubernetes.Clientset
 	dynamicClient dynamic.Interface
 	usedCPU       int64
 	usedRAM       int64
 	masterCPU     int64
+	deprecations  map[string]int
+	filter        scanFilter
 	// masterCPU     int64
 }
 
 type nameSpaceDetail struct {
 	name            string
+	labels          map[string]string
+	created         time.Time
 	ingresses       []ingressInfo
 	cronJobs        []cronJobInfo
 	pods            []podInfo
 	deployments     map[string]deployInfo
+	replicas        []deploymentReplicas
 	hpas            []hpaInfo
 	configMaps      []configMapInfo
 	secrets         []secretInfo
@@ -144,6 +126,14 @@ type nameSpaceDetail struct {
 	serviceAccounts []v1.ServiceAccount
 }
 
+// deploymentReplicas is how many replicas a Deployment asks for and how many it has.
+type deploymentReplicas struct {
+	name      string
+	desired   int32
+	ready     int32
+	available int32
+}
+
 type usageData struct {
 	name            string
 	totalCPURequest int64
@@ -178,9 +168,12 @@ type hpaInfo struct {
 
 type podStatusSummary struct {
 	running      int
+	notReady     int
 	pending      int
+	terminating  int
 	completed    int
 	failed       int
+	unknown      int
 	crashlooping int
 	other        int
 }
@@ -205,14 +198,28 @@ type podInfo struct {
 	podRunningTime              int64
 	ownerName                   string
 	ownerKind                   string
+	status                      string
+	nodeName                    string
+	tolerations                 []v1.Toleration
+	labels                      map[string]string
+	annotations                 map[string]string
+	containers                  []containerInfo
+	configMapRefs               []string
+	secretRefs                  []string
+	diagnosis                   podDiagnosis
+	scheduling                  schedulingInfo
 }
 
 type imageInfo struct {
-	count        int
-	imageKey     string
-	imageName    string
-	imageRepo    string
-	imageVersion string
+	count          int
+	imageKey       string
+	imageName      string
+	imageRepo      string
+	imageVersion   string
+	imageDigest    string
+	runningDigests []string
+	scanned        bool
+	vulns          map[string]int
 }
 
 type nodeInstanceType struct {
@@ -313,16 +320,62 @@ func main() {
 	var threadCount int
 	var printImageDetails bool
 	var printNodeSummary bool
+	var printStatusMatrixFlag bool
 	var trueColor bool
+	var renderMode string
 	var debugPrints bool
 	// var multiCluster bool
 	var summarizeDeprecated bool
 	var summarizeDeprecatedString string
+	var whatIfCatalogFile string
+	var whatIfCatalog []instanceShape
+	var pricingFile string
+	var teamLabel string
+	var costCSVFile string
+	var pricing pricingTable
+	var costReports []costReport
+	var imageHygiene bool
+	var imageHygieneByWorkload bool
+	var allowedRegistries string
+	var largeImageMB int64
+	var vulnDBPath string
+	var vulnDB advisoryDB
+	var eventMinutes int
+	var eventBuckets int
+	var watch bool
+	var watchInterval time.Duration
+	var metricsAddr string
+	var apiAddr string
+	var scanInterval time.Duration
+	var outputFormat string
+	var outputPath string
+	var namespaces stringList
+	var excludeNamespaces stringList
+	var excludeSystemNamespaces bool
+	var selector string
+	var nodeSelector string
+	var filter scanFilter
+	var sortBy string
+	var top int
+	var rank ranking
+	var historyPath string
+	var trendDays int
+	var trendWidth int
+	var reportTo string
+	var reportKeep int
+	var allContexts bool
+	var contextRegex string
+	var alertWebhooks stringList
+	var alertStatePath string
+	var alertCooldown time.Duration
+	var alerts *alerter
+	var configPath string
+	var profileName string
+	var command string
+	var commandArgs []string
 	//var wg sync.WaitGroup
 
 	// var showHelp bool
-	const restartLimit = 20
-	const lastRestartWarningTime = 20
 	const dark = 30
 	const light = 37
 	const red = 31
@@ -330,12 +383,11 @@ func main() {
 	const yellow = 33
 	var goodColor = colorString(green, false)
 	var errorColor = colorString(red, false)
-	//var warningColor = colorString(yellow, false)
+	var warningColor = colorString(yellow, false)
 	var normalColor = colorString(light, false)
 	var darkGray = colorString(dark, false)
 	var tagColors []rgb
 	var imageMap = make(map[string]imageInfo)
-	var podCounter = make(map[string]podInfo)
 	var deployAggregateDetails = make(map[string]deployInfo)
 	// var nsDetails = make(map[string]nameSpaceDetail)
 	var emptyNSDetails = make(map[string]nameSpaceDetail)
@@ -358,7 +410,7 @@ func main() {
 
 	if home != "" {
 		//		flag.StringVar(kubeConfig, "C", filepath.Join(home, ".kube", "config"), "kubernetes Config file (default: ~/.kube/config)")
-		kubeConfig = flag.String("kubeconfig", filepath.Join(home, ".kube", "config"), "(optional) absolute path to the kubeconfig file")
+		kubeConfig = flag.String("kubeconfig", "", "(optional) absolute path to the kubeconfig file (default: $KUBECONFIG, then "+filepath.Join(home, ".kube", "config")+")")
 	} else {
 		kubeConfig = flag.String("kubeconfig", "", "absolute path to the kubeconfig file")
 	}
@@ -368,25 +420,239 @@ func main() {
 	flag.BoolVar(&printPodDetails, "p", false, "(optional) List details about all pods")
 	flag.BoolVar(&printImageDetails, "i", false, "(optional) Print breakdown of Images used in the cluster")
 	flag.BoolVar(&printNodeSummary, "n", false, "(optional) Print Summary of Nodes")
-	flag.BoolVar(&trueColor, "t", true, "(optional) Use TrueColor terminal support (default: On)")
+	flag.BoolVar(&printStatusMatrixFlag, "s", false, "(optional) Print a pod status matrix per namespace")
+	flag.BoolVar(&trueColor, "t", true, "(optional) Use TrueColor terminal support (default: detected from $COLORTERM, 256 colors otherwise)")
+	flag.StringVar(&renderMode, "render", "auto", "(optional) Terminal output: auto, terminal, no-color or plain (auto is plain when not a TTY and honors NO_COLOR)")
 	flag.BoolVar(&summarizeDeprecated, "d", false, "(optional) Show a list of deprecated issues found at the end")
 	flag.IntVar(&threadCount, "T", 3, "(optional) Max Concurrent Threads (default: 3)")
-	flag.StringVar(&kubeContext, "c", "", "(optional) Kubernetes Context to use")
+	flag.StringVar(&kubeContext, "c", "", "(optional) Kubernetes Context to use, or several separated by commas (default: the current context)")
+	flag.StringVar(&historyPath, "history", defaultHistoryPath(), "(optional) SQLite file every scan appends its totals to and the trend command reads; empty to keep no history")
+	flag.IntVar(&trendDays, "trend-days", 30, "(optional) How many days back the trend and forecast commands look")
+	flag.IntVar(&trendWidth, "trend-width", 30, "(optional) How many points wide the trend sparklines are")
+	flag.StringVar(&reportTo, "report-to", "", "(optional) Where the report command publishes: configmap:[namespace/]name in the current cluster, an http(s) URL, or a directory")
+	flag.IntVar(&reportKeep, "report-keep", 10, "(optional) How many reports the report command keeps in a ConfigMap or directory")
+	flag.BoolVar(&allContexts, "all-contexts", false, "(optional) Scan every context in the kubeconfig")
+	flag.StringVar(&contextRegex, "context-regex", "", "(optional) Scan every context whose name matches this regular expression")
+	flag.Var(&alertWebhooks, "alert-webhook", "(optional) Webhook URL scans and --watch send alerts to (Slack, Teams or plain JSON); repeatable")
+	flag.StringVar(&alertStatePath, "alert-state", defaultAlertStatePath(), "(optional) JSON file remembering which alerts were sent, so they are not repeated")
+	flag.DurationVar(&alertCooldown, "alert-cooldown", time.Hour, "(optional) How long an alert that keeps firing waits before it is sent again")
+	flag.StringVar(&whatIfCatalogFile, "w", "", "(optional) Instance catalog (vcpu/memoryGiB/price) to calculate node counts against")
+	flag.StringVar(&pricingFile, "pricing", "", "(optional) Pricing file (hourly price per instance type and capacity type) for cost showback")
+	flag.StringVar(&teamLabel, "team-label", "team", "(optional) Pod/namespace label or annotation holding the owning team for cost showback")
+	flag.StringVar(&costCSVFile, "cost-csv", "", "(optional) Also write the cost showback to this CSV file")
+	flag.BoolVar(&imageHygiene, "image-hygiene", false, "(optional) Check images for latest tags, mutable tags, disallowed registries and large images pulled Always")
+	flag.BoolVar(&imageHygieneByWorkload, "image-hygiene-by-workload", true, "(optional) Group image hygiene findings by workload inside each namespace")
+	flag.StringVar(&allowedRegistries, "allowed-registries", "", "(optional) Comma separated registries images may come from, e.g. docker.io,quay.io")
+	flag.Int64Var(&largeImageMB, "large-image-mb", 1024, "(optional) Images at least this many MB should not use imagePullPolicy Always")
+	flag.StringVar(&vulnDBPath, "vuln-db", "", "(optional) Trivy/Grype JSON report file or directory to match running images against, no network needed")
+	flag.IntVar(&eventMinutes, "event-minutes", 60, "(optional) How far back the events command looks, in minutes")
+	flag.IntVar(&eventBuckets, "event-buckets", 12, "(optional) Number of time buckets in the events timeline")
+	flag.BoolVar(&watch, "watch", false, "(optional) Keep informers running and refresh the namespace, node and deployment breakdowns in place")
+	flag.DurationVar(&watchInterval, "watch-interval", 10*time.Second, "(optional) How often --watch refreshes")
+	flag.StringVar(&metricsAddr, "metrics-addr", ":9877", "(optional) Address the serve command exposes Prometheus metrics on")
+	flag.StringVar(&apiAddr, "api-addr", "", "(optional) Address the serve command exposes the JSON API on (default: same as -metrics-addr)")
+	flag.DurationVar(&scanInterval, "scan-interval", 5*time.Minute, "(optional) How often the serve command rescans the clusters")
+	flag.StringVar(&outputFormat, "o", "text", "(optional) Output format: text, json, html, markdown or csv")
+	flag.Var(&namespaces, "namespace", "(optional) Only scan namespaces matching this glob, or /regex/; repeatable")
+	flag.Var(&excludeNamespaces, "exclude-namespace", "(optional) Skip namespaces matching this glob, or /regex/; repeatable")
+	flag.BoolVar(&excludeSystemNamespaces, "exclude-system-namespaces", false, "(optional) Skip kube-system, kube-public and kube-node-lease")
+	flag.StringVar(&selector, "selector", "", "(optional) Label selector for the pods (and so the workloads) to scan")
+	flag.StringVar(&nodeSelector, "node-selector", "", "(optional) Label selector for the nodes to scan")
+	flag.StringVar(&sortBy, "sort-by", "name", "(optional) Order every breakdown by cpu, memory, pods, restarts, age or name")
+	flag.IntVar(&top, "top", 0, "(optional) Only show the first N rows of every breakdown (default: all)")
+	flag.StringVar(&configPath, "config", defaultConfigPath(), "(optional) YAML config file with named profiles")
+	flag.StringVar(&profileName, "profile", "", "(optional) Config profile to use (default: the config's defaultProfile)")
+	flag.StringVar(&outputPath, "out", "", "(optional) Write -o output to this file instead of stdout; for csv a directory gets one file per table")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] [find image|label|owner|ref <value> | events | tui | serve | report | trend | forecast]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
 	flag.Parse()
 
+	// Anything after the flags is a command, which may be followed by more flags and its arguments.
+	if flag.NArg() > 0 {
+		command = flag.Arg(0)
+		flag.CommandLine.Parse(flag.Args()[1:])
+		commandArgs = flag.Args()
+	}
+	switch command {
+	case "", "find", "events", "tui", "serve", "report", "trend", "forecast":
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", command)
+		flag.Usage()
+		os.Exit(2)
+	}
+	if command == "find" {
+		if err := checkFindArgs(commandArgs); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	}
+	// Profile values fill in every flag that was not given on the command line.
+	var configGiven bool
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "config" {
+			configGiven = true
+		}
+	})
+	cfg, err := loadConfig(configPath, configGiven)
+	if err != nil {
+		panic(err.Error())
+	}
+	if p, ok, err := cfg.selectProfile(profileName); err != nil {
+		panic(err.Error())
+	} else if ok {
+		if err := applyProfile(p); err != nil {
+			panic(err.Error())
+		}
+	}
+
+	// -t only overrides TrueColor detection when it was given.
+	var trueColorOverride *bool
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "t" {
+			trueColorOverride = &trueColor
+		}
+	})
+	if r, err := newRenderer(renderMode, trueColorOverride); err == nil {
+		output = r
+		goodColor = colorString(green, false)
+		errorColor = colorString(red, false)
+		warningColor = colorString(yellow, false)
+		normalColor = colorString(light, false)
+		darkGray = colorString(dark, false)
+	} else {
+		fmt.Fprintln(os.Stderr, err)
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if command == "report" {
+		if reportTo == "" {
+			fmt.Fprintln(os.Stderr, "the report command needs --report-to")
+			flag.Usage()
+			os.Exit(2)
+		}
+		if outputFormat == "text" {
+			outputFormat = "json"
+		}
+	}
+
+	switch outputFormat {
+	case "text", "json", "html", "markdown", "csv":
+	default:
+		fmt.Fprintf(os.Stderr, "unknown output format %q\n", outputFormat)
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if command == "events" && eventMinutes < 1 {
+		fmt.Fprintln(os.Stderr, "-event-minutes must be at least 1")
+		flag.Usage()
+		os.Exit(2)
+	}
+	if watch && watchInterval < time.Second {
+		fmt.Fprintln(os.Stderr, "-watch-interval must be at least 1s")
+		flag.Usage()
+		os.Exit(2)
+	}
+	if command == "serve" && scanInterval < time.Second {
+		fmt.Fprintln(os.Stderr, "-scan-interval must be at least 1s")
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if f, err := newScanFilter(namespaces, excludeNamespaces, excludeSystemNamespaces, selector, nodeSelector); err == nil {
+		filter = f
+	} else {
+		fmt.Fprintln(os.Stderr, err)
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if r, err := newRanking(sortBy, top); err == nil {
+		rank = r
+	} else {
+		fmt.Fprintln(os.Stderr, err)
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if a, err := newAlerter(settings.Alerts, alertWebhooks, alertStatePath, alertCooldown); err == nil {
+		alerts = a
+	} else {
+		fmt.Fprintln(os.Stderr, err)
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if vulnDBPath != "" {
+		db, err := loadAdvisoryDB(vulnDBPath)
+		if err != nil {
+			panic(err.Error())
+		}
+		vulnDB = db
+	}
+
+	if pricingFile != "" {
+		p, err := loadPricing(pricingFile)
+		if err != nil {
+			panic(err.Error())
+		}
+		pricing = p
+	}
+
+	if whatIfCatalogFile != "" {
+		catalog, err := loadInstanceCatalog(whatIfCatalogFile)
+		if err != nil {
+			panic(err.Error())
+		}
+		whatIfCatalog = catalog
+	}
+
 	tagColors = makeTagColors(trueColor)
 	var progressBar *progressbar.ProgressBar
 
-	kubeContexts = strings.Split(kubeContext, ",")
+	if kubeContext != "" {
+		kubeContexts = strings.Split(kubeContext, ",")
+	}
 
-	if len(kubeContexts) > 0 {
-		clusterCount = len(kubeContexts)
-		//multiCluster = true
-	} else {
-		clusterCount = 1
-		//multiCluster = false
+	// The trend and forecast commands only read the history, they do not need a cluster.
+	if command == "trend" {
+		if trendDays < 1 || trendWidth < 1 {
+			fmt.Fprintln(os.Stderr, "-trend-days and -trend-width must be at least 1")
+			os.Exit(2)
+		}
+		if err := runTrend(historyPath, kubeContexts, trendDays, trendWidth, rank); err != nil {
+			panic(err.Error())
+		}
+		return
+	}
+	if command == "forecast" {
+		if trendDays < 1 {
+			fmt.Fprintln(os.Stderr, "-trend-days must be at least 1")
+			os.Exit(2)
+		}
+		if err := runForecast(historyPath, kubeContexts, trendDays, rank); err != nil {
+			panic(err.Error())
+		}
+		return
+	}
+
+	// A destination that cannot work should fail before the scan rather than after it.
+	var sink reportSink
+	if command == "report" {
+		if sink, err = newReportSink(reportTo, reportKeep, *kubeConfig); err != nil {
+			panic(err.Error())
+		}
 	}
 
+	targets, err := resolveClusterTargets(*kubeConfig, kubeContexts, allContexts, contextRegex)
+	if err != nil {
+		panic(err.Error())
+	}
+	clusterCount = len(targets)
+
 	// Initialize Some Arrays
 	for clusterNum := 0; clusterNum < clusterCount; clusterNum++ {
 		overallPodStatuses = append(overallPodStatuses, podStatusSummary{
@@ -399,24 +665,8 @@ func main() {
 		})
 	}
 
-	deployNameWidth := 0
-
-	for clusterNum := 0; clusterNum < clusterCount; clusterNum++ {
-		var contextToUse string
-
-		configOverrides := &clientcmd.ConfigOverrides{}
-
-		if len(kubeContexts[clusterNum]) > 0 {
-			contextToUse = kubeContexts[clusterNum]
-			configOverrides = &clientcmd.ConfigOverrides{CurrentContext: contextToUse}
-		}
-
-		configLoadingRules := &clientcmd.ClientConfigLoadingRules{ExplicitPath: *kubeConfig}
-
-		config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(configLoadingRules, configOverrides).ClientConfig()
-		if err != nil {
-			panic(err.Error())
-		}
+	for _, target := range targets {
+		config := target.config
 
 		// create the clientset
 		clientset, err := kubernetes.NewForConfig(config)
@@ -441,23 +691,41 @@ func main() {
 		// 	defer wg.Done()
 
 		var currentCluster clusterDetail
-		currentCluster.name = "First" // Can I get this somewhere? Context?
+		currentCluster.name = target.context
+		if version, err := clientset.Discovery().ServerVersion(); err == nil {
+			currentCluster.version = version.GitVersion
+		} else if len(targets) > 1 {
+			// One unreachable context should not stop a scan of all the others.
+			fmt.Fprintf(os.Stderr, "Skipping %s: %v\n", target.context, err)
+			clusterCount--
+			overallPodStatuses = overallPodStatuses[:clusterCount]
+			continue
+		}
 		currentCluster.clientset = *clientset
 		currentCluster.dynamicClient = dynamicClient
+		currentCluster.filter = filter
+		if watch {
+			// Informers replace the one-shot scan.
+			clusterDetails = append(clusterDetails, currentCluster)
+			continue
+		}
 		// wg.Add(1)
 		// go func(nsName string) {
 		// 	defer wg.Done()
-		fmt.Println("Scanning Clusters...")
+		fmt.Fprintf(os.Stderr, "Scanning %s...\n", describeCluster(target, currentCluster.version))
 		progressBar = progressbar.Default(int64(clusterCount * 100))
-		currentCluster.namespaces = scanClusterNamespaces(clientset, dynamicClient, progressBar)
+		currentCluster.namespaces, err = scanClusterNamespaces(clientset, dynamicClient, progressBar, filter)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Incomplete scan of %s: %v\n", target.context, err)
+		}
 		//currentCluster.nodes = scanClusterPods(clientset, dynamicClient)
 
-		//nodes, _ := clientset.CoreV1().Nodes().List(context.TODO(), metav1.ListOptions{})
-		//currentCluster.nodes = nodes
+		nodes, _ := clientset.CoreV1().Nodes().List(context.TODO(), filter.nodeListOptions())
+		currentCluster.nodeList = nodes.Items
 
 		clusterDetails = append(clusterDetails, currentCluster)
 
-		fmt.Printf("Found %d namespaces\n", len(clusterDetails[0].namespaces))
+		fmt.Fprintf(os.Stderr, "Found %d namespaces\n", len(currentCluster.namespaces))
 
 		// grab info for Namespace
 		// mutex.Lock()
@@ -471,6 +739,16 @@ func main() {
 		//}
 	}
 
+	if len(clusterDetails) == 0 {
+		fmt.Fprintln(os.Stderr, "None of the contexts could be reached")
+		os.Exit(1)
+	}
+
+	if watch {
+		runWatch(clusterDetails, watchInterval, alerts)
+		return
+	}
+
 	for clusterNum := 0; clusterNum < clusterCount; clusterNum++ {
 		var nsTotalRAM int64
 		var nsTotalCPU int64
@@ -488,14 +766,6 @@ func main() {
 			// }
 			nsNames = append(nsNames, ns.name)
 
-			for p := 0; p < len(ns.pods); p++ {
-				podCounter[ns.pods[p].HostIP] = podInfo{
-					count:          podCounter[ns.pods[p].HostIP].count + 1,
-					reservedMemory: podCounter[ns.pods[p].HostIP].reservedMemory + ns.pods[p].reservedMemory,
-					reservedCPU:    podCounter[ns.pods[p].HostIP].reservedCPU + ns.pods[p].reservedCPU,
-				}
-			}
-
 			// No Pods, No Ingresses, No VirtualServices ... Probably should add more things here, or
 			// a function... yeah, a function.
 			//fmt.Printf("%s: secrets: %d\t configmaps: %d", ns.name, len(ns.secrets), len(ns.configMaps))
@@ -507,189 +777,239 @@ func main() {
 
 			// pull nsDetail info into overall imageMap
 			for _, info := range ns.images {
-				if _, ok := imageMap[info.imageKey]; ok {
+				mergeImage(imageMap, info)
+			}
+
+			// pull nsDetail info into overall deployAggregateDetails
+			for _, info := range ns.deployments {
+				if _, ok := deployAggregateDetails[info.name]; ok {
 					// increment
-					imageMap[info.imageKey] = imageInfo{
-						count:        imageMap[info.imageKey].count + 1,
-						imageName:    info.imageName,
-						imageRepo:    info.imageRepo,
-						imageVersion: info.imageVersion,
+					deployAggregateDetails[info.name] = deployInfo{
+						name:            info.name,
+						count:           deployAggregateDetails[info.name].count + info.count,
+						totalCPURequest: deployAggregateDetails[info.name].totalCPURequest + info.totalCPURequest,
+						totalRAMRequest: deployAggregateDetails[info.name].totalRAMRequest + info.totalRAMRequest,
 					}
 				} else {
-					imageMap[info.imageKey] = imageInfo{
-						imageName:    info.imageName,
-						imageVersion: info.imageVersion,
-						imageRepo:    info.imageRepo,
-						count:        1,
-					}
-				}
-
-				// pull nsDetail info into overall deployAggregateDetails
-				for _, info := range ns.deployments {
-					thisWidth := len(info.name)
-					if thisWidth > deployNameWidth {
-						deployNameWidth = thisWidth
+					deployAggregateDetails[info.name] = deployInfo{
+						name:            info.name,
+						count:           info.count,
+						totalCPURequest: info.totalCPURequest,
+						totalRAMRequest: info.totalRAMRequest,
 					}
-					if _, ok := deployAggregateDetails[info.name]; ok {
-						// increment
-						deployAggregateDetails[info.name] = deployInfo{
-							count:           deployAggregateDetails[info.name].count + info.count,
-							totalCPURequest: deployAggregateDetails[info.name].totalCPURequest + info.totalCPURequest,
-							totalRAMRequest: deployAggregateDetails[info.name].totalRAMRequest + info.totalRAMRequest,
-						}
-					} else {
-						deployAggregateDetails[info.name] = deployInfo{
-							name:            info.name,
-							count:           info.count,
-							totalCPURequest: info.totalCPURequest,
-							totalRAMRequest: info.totalRAMRequest,
-						}
-					}
-				}
-				//fmt.Printf("There are %d pods in the namespace %s\n", len(pods.Items), namespaces.Items[n].Name)
-
-				overallPodStatuses[clusterNum] = podStatusSummary{
-					running:      overallPodStatuses[clusterNum].running + ns.statusSummary.running,
-					pending:      overallPodStatuses[clusterNum].pending + ns.statusSummary.pending,
-					completed:    overallPodStatuses[clusterNum].completed + ns.statusSummary.completed,
-					failed:       overallPodStatuses[clusterNum].failed + ns.statusSummary.failed,
-					crashlooping: overallPodStatuses[clusterNum].crashlooping + ns.statusSummary.crashlooping,
-					other:        overallPodStatuses[clusterNum].other + ns.statusSummary.other,
-				}
-				nsTotalCPU = nsTotalCPU + ns.totalCPURequest
-				nsTotalRAM = nsTotalRAM + ns.totalRAMRequest
-
-				if len(ns.pods) > 0 && printPodDetails {
-					printNameSpaceDetails(ns, nsTotalCPU, nsTotalRAM)
 				}
 			}
+			//fmt.Printf("There are %d pods in the namespace %s\n", len(pods.Items), namespaces.Items[n].Name)
 
-			clusterDetails[clusterNum].usedCPU = clusterDetails[clusterNum].usedCPU + nsTotalCPU/1000 // nsTotalCPU is in milliCPU
-			clusterDetails[clusterNum].usedRAM = clusterDetails[clusterNum].usedRAM + nsTotalRAM
+			overallPodStatuses[clusterNum] = overallPodStatuses[clusterNum].plus(ns.statusSummary)
+			nsTotalCPU = nsTotalCPU + ns.totalCPURequest
+			nsTotalRAM = nsTotalRAM + ns.totalRAMRequest
 
 			progressBar.Add(1)
 		}
+
+		if printPodDetails && outputFormat == "text" {
+			for _, name := range rank.namespaces(clusterDetails[clusterNum], true) {
+				printNameSpaceDetails(clusterDetails[clusterNum].namespaces[name], nsTotalCPU, nsTotalRAM, rank)
+			}
+		}
+
+		clusterDetails[clusterNum].usedCPU = nsTotalCPU / 1000 // nsTotalCPU is in milliCPU
+		clusterDetails[clusterNum].usedRAM = nsTotalRAM
 	} // End Cluster Scans
 
+	if historyPath != "" && (command == "" || command == "report") {
+		if filter.limited() {
+			fmt.Fprintln(os.Stderr, "Not recording history for a filtered scan, its totals would not compare with full scans")
+		} else if err := recordHistory(historyPath, clusterDetails, time.Now()); err != nil {
+			fmt.Fprintf(os.Stderr, "Not recording history: %v\n", err)
+		}
+	}
+
+	if alerts != nil && (command == "" || command == "report") {
+		if err := alerts.check(clusterDetails, false); err != nil {
+			fmt.Fprintf(os.Stderr, "Alerting: %v\n", err)
+		}
+	}
+
+	if command == "find" {
+		runFind(clusterDetails, commandArgs)
+		return
+	}
+
+	if command == "serve" {
+		runServe(clusterDetails, metricsAddr, apiAddr, scanInterval, vulnDB)
+		return
+	}
+
+	if command == "tui" {
+		if err := runTUI(clusterDetails); err != nil {
+			panic(err.Error())
+		}
+		return
+	}
+
+	if command == "events" {
+		window := time.Duration(eventMinutes) * time.Minute
+		if eventBuckets < 1 {
+			eventBuckets = 1
+		}
+		for _, cluster := range clusterDetails {
+			events := collectEvents(&cluster.clientset, time.Now().Add(-window), cluster.filter)
+			linkEventWorkloads(events, cluster)
+			printEventTimeline(cluster, events, window, eventBuckets)
+		}
+		return
+	}
+
+	if vulnDBPath != "" {
+		annotateVulnerabilities(imageMap, vulnDB)
+	}
+
+	// Costs are worked out ahead of the output branches so -cost-csv is written, and the cost
+	// table included, whatever -o is.
+	if pricingFile != "" {
+		for _, cluster := range clusterDetails {
+			costReports = append(costReports, allocateCosts(cluster, pricing, teamLabel))
+		}
+		if costCSVFile != "" {
+			if err := writeCostCSV(costCSVFile, costReports); err != nil {
+				panic(err.Error())
+			}
+		}
+	}
+
+	// render writes every non-terminal format; csvDir is only used for csv.
+	render := func(out io.Writer, csvDir string) error {
+		switch outputFormat {
+		case "json":
+			return writeJSONReport(out, clusterDetails, costReports, vulnDB)
+		case "html":
+			return writeHTMLReport(out, clusterDetails, deployAggregateDetails, imageMap, costReports, tagColors, rank)
+		case "markdown":
+			return markdownRenderer{w: out}.render(reportTables(clusterDetails, deployAggregateDetails, imageMap, costReports, rank))
+		case "csv":
+			return csvRenderer{w: out, dir: csvDir}.render(reportTables(clusterDetails, deployAggregateDetails, imageMap, costReports, rank))
+		}
+		return nil
+	}
+
+	if command == "report" {
+		if err := runReport(sink, outputFormat, func(w io.Writer) error { return render(w, "") }); err != nil {
+			panic(err.Error())
+		}
+		return
+	}
+
+	if outputFormat != "text" {
+		out := os.Stdout
+		csvDir := ""
+		if info, err := os.Stat(outputPath); outputFormat == "csv" && (strings.HasSuffix(outputPath, "/") || (err == nil && info.IsDir())) {
+			csvDir = outputPath
+		} else if outputPath != "" {
+			file, err := os.Create(outputPath)
+			if err != nil {
+				panic(err.Error())
+			}
+			defer file.Close()
+			out = file
+		}
+
+		if err := render(out, csvDir); err != nil {
+			panic(err.Error())
+		}
+		return
+	}
+
 	// Cluster Pod Breakdown
 	for clusterNum := 0; clusterNum < len(clusterDetails); clusterNum++ {
 		fmt.Printf("\n - There are %d namespaces, %d of which are empty:\n", len(clusterDetails[clusterNum].namespaces), emptyNamespaces)
-		for _, info := range emptyNSDetails {
-			if info.name != "" {
+		for _, name := range sortedNamespaceNames(emptyNSDetails) {
+			if info := emptyNSDetails[name]; info.name != "" {
 				fmt.Printf("   %s· %s%s%s\n", normalColor, colorString(yellow, false), info.name, normalColor)
 			}
 		}
 
-		var pendingColor = goodColor
-		var otherColor = goodColor
-		if overallPodStatuses[clusterNum].pending > 0 {
-			pendingColor = errorColor
-		}
-		if overallPodStatuses[clusterNum].pending > 0 || overallPodStatuses[clusterNum].failed > 0 {
-			otherColor = errorColor
+		statuses := overallPodStatuses[clusterNum]
+		countColor := func(count int, badColor string) string {
+			if count > 0 {
+				return badColor
+			}
+			return goodColor
 		}
 		if printPodDetails {
-			fmt.Printf("\n - Pod Status Breakdown: %d Running - %s%d%s Pending - %s%d%s Failed - %s%d%s Completed - %s%d%s Other\n",
-				overallPodStatuses[clusterNum].running,
-				pendingColor, overallPodStatuses[clusterNum].pending, normalColor,
-				pendingColor, overallPodStatuses[clusterNum].failed, normalColor,
-				pendingColor, overallPodStatuses[clusterNum].completed, normalColor,
-				otherColor, overallPodStatuses[clusterNum].other, normalColor)
+			fmt.Printf("\n - Pod Status Breakdown: %d Running - %s%d%s NotReady - %s%d%s Pending - %s%d%s Terminating - %s%d%s Failed - %s%d%s Unknown - %d Completed - %s%d%s CrashLooping - %s%d%s Other\n",
+				statuses.running,
+				countColor(statuses.notReady, warningColor), statuses.notReady, normalColor,
+				countColor(statuses.pending, errorColor), statuses.pending, normalColor,
+				countColor(statuses.terminating, warningColor), statuses.terminating, normalColor,
+				countColor(statuses.failed, errorColor), statuses.failed, normalColor,
+				countColor(statuses.unknown, errorColor), statuses.unknown, normalColor,
+				statuses.completed,
+				countColor(statuses.crashlooping, errorColor), statuses.crashlooping, normalColor,
+				countColor(statuses.other, warningColor), statuses.other, normalColor)
+		}
+		if printStatusMatrixFlag {
+			printStatusMatrix(clusterDetails[clusterNum])
 		}
-	}
 
-	fmt.Printf("\n%s===== %sDeployment Breakdown%s =====%s\n", darkGray, goodColor, darkGray, normalColor)
-	for Deployment, info := range deployAggregateDetails {
-		fmt.Printf("\t%*d x %*s: %5d vCPU, %4d GiB RAM Requested\n", 3, info.count, deployNameWidth, Deployment, info.totalCPURequest, info.totalRAMRequest/1024/1024/1024)
+		printProblemPods(clusterDetails[clusterNum], settings.Thresholds.RestartLimit, time.Duration(settings.Thresholds.RestartWindowMinutes)*time.Minute)
+		printPendingPods(clusterDetails[clusterNum])
 	}
-	fmt.Println()
 
+	breakdowns := []reportTable{deploymentTable(clusterDetails, deployAggregateDetails, rank)}
 	if printImageDetails {
-		fmt.Printf("\n%s===== %sImage Breakdown%s =====%s\n", darkGray, goodColor, darkGray, normalColor)
-		for _, info := range imageMap {
-			if info.imageName != "" {
-				fmt.Printf(" %s- %s%4d %48s: %48s\n", darkGray, goodColor, info.count, info.imageName, info.imageVersion)
-			}
-		}
-	} // End printImageDetails
+		breakdowns = append(breakdowns, imageTable(imageMap, rank))
+	}
+	if err := (textRenderer{os.Stdout}).render(breakdowns); err != nil {
+		panic(err.Error())
+	}
+
+	if vulnDBPath != "" {
+		printVulnerableImages(imageMap, clusterDetails)
+	}
 
 	for clusterNum := 0; clusterNum < len(clusterDetails); clusterNum++ {
 
 		if printNodeSummary {
-			nodes, _ := clusterDetails[clusterNum].clientset.CoreV1().Nodes().List(context.TODO(), metav1.ListOptions{})
-			var defaultColor string = "\033[38;2;192;192;192m"
-			var bold = false
+			cluster := clusterDetails[clusterNum]
+			nodes := cluster.nodeList
+			var defaultColor string = output.rgb(rgb{192, 192, 192}, nil, false)
 			var color = 37
 			var nameWidth = 0
-			var instanceNameWidth = 0
 			var totalCores int64 = 0
 			var totalRAM int64 = 0
 			var totalVolumes = 0
-			var typeBreakdown = make(map[string]nodeInstanceType)
 
 			var workload_types []string
 			var workload_typeWidth = 0
 
 			// Figure out widths and counts
-			for i := 0; i < len(nodes.Items); i++ {
-				labels := nodes.Items[i].GetLabels()
-
+			for i := 0; i < len(nodes); i++ {
 				// record taints
-				for t := 0; t < len(nodes.Items[i].Spec.Taints); t++ {
-					if nodes.Items[i].Spec.Taints[t].Key == "workload_type" {
-						curKey := nodes.Items[i].Spec.Taints[t].Value
+				for t := 0; t < len(nodes[i].Spec.Taints); t++ {
+					if nodes[i].Spec.Taints[t].Key == settings.NodeGroupKey {
+						curKey := nodes[i].Spec.Taints[t].Value
 						if !contains(workload_types, curKey) {
-							if len(nodes.Items[i].Spec.Taints[t].Value) > workload_typeWidth {
-								workload_typeWidth = len(nodes.Items[i].Spec.Taints[t].Value)
+							if len(nodes[i].Spec.Taints[t].Value) > workload_typeWidth {
+								workload_typeWidth = len(nodes[i].Spec.Taints[t].Value)
 							}
-							workload_types = append(workload_types, nodes.Items[i].Spec.Taints[t].Value)
+							workload_types = append(workload_types, nodes[i].Spec.Taints[t].Value)
 
 						}
 					}
 				}
-				//			nodeGroup := labels["eks.amazonaws.com/nodegroup"]
 
-				thisWidth := len(nodes.Items[i].Status.Addresses[1].Address)
+				thisWidth := len(nodes[i].Name)
 				if thisWidth > nameWidth {
 					nameWidth = thisWidth
 				}
 
-				thisInstanceWidth := len(labels["beta.kubernetes.io/instance-type"])
-				if thisInstanceWidth > instanceNameWidth {
-					instanceNameWidth = thisInstanceWidth
-				}
-
-				cores, _ := nodes.Items[i].Status.Capacity.Cpu().AsInt64()
+				cores, _ := nodes[i].Status.Capacity.Cpu().AsInt64()
 				totalCores = totalCores + cores
-				RAM, _ := nodes.Items[i].Status.Capacity.Memory().AsInt64()
+				RAM, _ := nodes[i].Status.Capacity.Memory().AsInt64()
 				totalRAM = totalRAM + RAM
-				storage, _ := nodes.Items[i].Status.Capacity.Storage().AsInt64()
-				// I thought this would include nvme, but it does not.
-				//storageEphemeral, _ := nodes.Items[i].Status.Capacity.StorageEphemeral().AsInt64()
-				//storage = storage + storageEphemeral
-				totalVolumes += len(nodes.Items[i].Status.VolumesAttached)
-
-				if _, ok := typeBreakdown[labels["beta.kubernetes.io/instance-type"]]; ok {
-					// increment
-					typeBreakdown[labels["beta.kubernetes.io/instance-type"]] = nodeInstanceType{
-						name:    labels["beta.kubernetes.io/instance-type"],
-						count:   typeBreakdown[labels["beta.kubernetes.io/instance-type"]].count + 1,
-						vCPU:    cores,
-						RAM:     RAM,
-						storage: storage,
-						//					group:   append(typeBreakdown[labels["beta.kubernetes.io/instance-type"]].group, nodeGroup),
-					}
-				} else {
-					typeBreakdown[labels["beta.kubernetes.io/instance-type"]] = nodeInstanceType{
-						name:    labels["beta.kubernetes.io/instance-type"],
-						count:   1,
-						vCPU:    cores,
-						RAM:     RAM,
-						storage: storage,
-						//					group:   appendIfUnique(typeBreakdown[labels["beta.kubernetes.io/instance-type"]].group, nodeGroup),
-					}
-				}
-
+				totalVolumes += len(nodes[i].Status.VolumesAttached)
 			}
 
 			// // check for bad helm secrets:
@@ -701,14 +1021,13 @@ func main() {
 			// 	}
 			// }
 
-			fmt.Printf("\n%s===== %sInstance Type Breakdown%s =====%s\n", darkGray, goodColor, darkGray, normalColor)
-			for _, info := range typeBreakdown {
-				fmt.Printf("\t%*d x %*s: %3d vCPU, %3d GiB RAM, %4d GiB local storage\n", 3, info.count, instanceNameWidth, info.name, info.vCPU, info.RAM/1024/1024/1024, info.storage)
+			if err := (textRenderer{os.Stdout}).render([]reportTable{instanceTypeTable([]clusterDetail{cluster}, rank)}); err != nil {
+				panic(err.Error())
 			}
 			fmt.Println()
 
 			fmt.Printf("\n%s===== %sNode Breakdown%s =====%s\n", darkGray, goodColor, darkGray, normalColor)
-			detail := fmt.Sprintf(" There are %d nodes, using %d Volumes in the cluster with a total of %d/%d Cores and %d/%d GB of RAM ", len(nodes.Items), totalVolumes, clusterDetails[clusterNum].usedCPU, totalCores, clusterDetails[clusterNum].usedRAM/1073741824, totalRAM/1073741824)
+			detail := fmt.Sprintf(" There are %d nodes, using %d Volumes in the cluster with a total of %d/%d Cores and %d/%d GB of RAM ", len(nodes), totalVolumes, cluster.usedCPU, totalCores, cluster.usedRAM/1073741824, totalRAM/1073741824)
 			fmt.Println(detail)
 			var detailLine string
 			for i := 0; i < len(detail); i++ {
@@ -717,223 +1036,158 @@ func main() {
 			fmt.Printf("%s\n%s     ", detailLine, defaultColor)
 
 			for c, info := range workload_types {
-				typeColor := fmt.Sprintf("\033[38;2;%d;%d;%dm", tagColors[c].red, tagColors[c].green, tagColors[c].blue)
+				typeColor := output.rgb(tagColors[c], nil, false)
 				fmt.Printf("%s  ⬤  %*s", typeColor, workload_typeWidth, info)
 			}
 			fmt.Printf("\n\n")
 
-			for i := 0; i < len(nodes.Items); i++ {
-				var nodeColorStripe string
-				var azColor string
-				var taintColor string
-				var workloadColor string = colorString(37, false)
-				var capacityTypeColor string
+			// The rows are those of the node table in the markdown, csv and report outputs.
+			summaries, _ := summarizeNodes(cluster)
+			for i, node := range rank.nodeSummaries(cluster, summaries) {
 				var tcBackground = (i%2)*15 + 25
-				var timeToLive string = ""
-				var instanceType string
-
-				warnings := ""
-				create := nodes.Items[i].CreationTimestamp.Unix()
-				capacity := nodes.Items[i].Status.Capacity
-				totalMemorySize := resource.MustParse(capacity.Memory().String())
-				hostName := nodes.Items[i].Status.Addresses[1].Address
-				ipAddress := nodes.Items[i].Status.Addresses[0].Address
-				labels := nodes.Items[i].GetLabels()
-				Taints := nodes.Items[i].Spec.Taints
-				instanceType = labels["beta.kubernetes.io/instance-type"]
-				nodeGroup := "default"
 				nodeColors := rgb{
 					red:   192,
 					green: 192,
 					blue:  192,
 				}
 
-				for j := 0; j < len(Taints); j++ {
-					taint := Taints[j]
-					if taint.Key == "workload_type" {
-						nodeGroup = taint.Value
-					}
-				}
-
-				capacityType, exist := labels["eks.amazonaws.com/capacityType"]
-				if exist && capacityType == "ON_DEMAND" {
-					bold = true
-				} else {
-					bold = false
-				}
-
-				zone, exist := labels["failure-domain.beta.kubernetes.io/zone"]
-				if exist {
-					color = int(zone[len(zone)-1]) - int('a') + 33
+				if node.Zone != "" {
+					color = int(node.Zone[len(node.Zone)-1]) - int('a') + 33
 				} else {
 					color = 37
 				}
 
 				normalColor := colorString(37, false)
-				//nodeColor = colorString(37, false)
-				if nodes.Items[i].Spec.Unschedulable {
-					//nodeColor = colorString(31, true)
-					warnings = warnings + "🚫 "
-				}
 				// TODO Warn if:
 				//taint: DeletionCandidateOfClusterAutoscaler is set
 
 				// Default
-				workloadColor = fmt.Sprintf("\033[38;2;%d;%d;%d;48;2;%d;%d;%dm", 192, 192, 192, tcBackground, tcBackground, tcBackground)
-
-				for t := 0; t < len(nodes.Items[i].Spec.Taints); t++ {
-					if nodes.Items[i].Spec.Taints[t].Key == "workload_type" {
-						if c, ok := inSlice(workload_types, nodes.Items[i].Spec.Taints[t].Value); ok {
-							if trueColor {
-								workloadColor = fmt.Sprintf("\033[38;2;%d;%d;%d;48;2;%d;%d;%dm", tagColors[c].red, tagColors[c].green, tagColors[c].blue, tcBackground, tcBackground, tcBackground)
-							}
-						}
-					}
-					//    | Taint ToBeDeletedByClusterAutoscaler => 1663865519
-					if nodes.Items[i].Spec.Taints[t].Key == "DeletionCandidateOfClusterAutoscaler" {
-						warnings = warnings + "🗑 "
-						//timeToDie, _ := strconv.ParseInt(nodes.Items[i].Spec.Taints[t].Value, 10, 64)
-						//timeToLive = fmt.Sprintf("🗑  in %s", secDiff(time.Now().Unix()-timeToDie))
-					}
-					if nodes.Items[i].Spec.Taints[t].Key == "node.kubernetes.io/not-ready" {
-						warnings = warnings + "✨ "
-					}
-					if nodes.Items[i].Spec.Taints[t].Key == "node.kubernetes.io/disk-pressure" {
-						warnings = warnings + "💾 "
-					}
-					if nodes.Items[i].Spec.Taints[t].Key == "eks.amazonaws.com/compute-type" {
-						instanceType = "Fargate  "
-					} //=> fargate ???
-
-					//}
-				}
-				if trueColor {
-					nodeColorStripe = fmt.Sprintf("\033[38;2;%d;%d;%d;48;2;%d;%d;%dm", nodeColors.red, nodeColors.green, nodeColors.blue, tcBackground, tcBackground, tcBackground)
-					capacityTypeColor = fmt.Sprintf("\033[38;2;192;192;192;48;2;%d;%d;%d;1m", tcBackground, tcBackground, tcBackground)
-					taintColor = fmt.Sprintf("\033[38;2;164;164;164;48;2;%d;%d;%dm", tcBackground, tcBackground, tcBackground)
-					azColor = fmt.Sprintf("\033[%d;48;2;%d;%d;%dm", color, tcBackground, tcBackground, tcBackground)
-				} else {
-					nodeColorStripe = fmt.Sprintf("%s%s", colorString(37, (i%2 == 0)), colorString(7, (i%2 == 0)))
-					azColor = fmt.Sprintf("\033[%dm;", color)
-					capacityTypeColor = colorString(37, bold)
-					taintColor = normalColor
+				stripe := rgb{tcBackground, tcBackground, tcBackground}
+				workloadColor := output.rgb(rgb{192, 192, 192}, &stripe, false)
+				if c, ok := inSlice(workload_types, node.Group); ok {
+					workloadColor = output.rgb(tagColors[c], &stripe, false)
 				}
 
-				//colorCode := colorString(color, bold)
-				if exist { // We're FRC or in AWS with the appropriate labels set
+				// The renderer falls back to the 256-color palette, or to nothing, as the terminal allows.
+				nodeColorStripe := output.rgb(nodeColors, &stripe, false)
+				capacityTypeColor := output.rgb(rgb{192, 192, 192}, &stripe, node.CapacityType == "ON_DEMAND")
+				taintColor := output.rgb(rgb{164, 164, 164}, &stripe, false)
+				azColor := output.colorOn(color, stripe)
+
+				if node.Zone != "" { // We're FRC or in AWS with the appropriate labels set
 					if printPodDetails {
-						fmt.Printf("%s%8s %12s %6s old %s%42s%s is %s%9s%s in %s%10s%s & %d taints - %2s CPUs %3v Gi (%12s) %14s, %d Labels %d Vols%s. %3d Pods w/req: %2d vCPU, %3d GiB Mem %s\n",
+						fmt.Printf("%s%8s %12s %6s old %s%s%s is %s%9s%s in %s%10s%s & %d taints - %2s CPUs %3v Gi (%12s) %14s, %d Labels %d Vols%s. %3d Pods w/req: %2d vCPU, %3d GiB Mem\n",
 							nodeColorStripe,
-							warnings,
-							nodeGroup,
-							secDiff(time.Now().Unix()-create),
+							node.Warnings,
+							node.Group,
+							node.Age,
 							workloadColor,
-							hostName,
+							fitColumn(node.Name, 42),
 							nodeColorStripe,
 							capacityTypeColor,
-							labels["eks.amazonaws.com/capacityType"],
+							node.CapacityType,
 							nodeColorStripe,
 							azColor,
-							labels["failure-domain.beta.kubernetes.io/zone"],
+							node.Zone,
 							nodeColorStripe,
-							len(nodes.Items[i].Spec.Taints),
-							capacity.Cpu().String(),
-							totalMemorySize.Value()/1024/1024/1024,
-							instanceType,
-							ipAddress,
-							len(nodes.Items[i].Labels),
-							len(nodes.Items[i].Status.VolumesAttached),
+							len(node.Taints),
+							node.CPUs,
+							node.MemoryGiB,
+							node.InstanceType,
+							node.IP,
+							node.Labels,
+							node.Volumes,
 							nodeColorStripe,
-							podCounter[ipAddress].count,
-							podCounter[ipAddress].reservedCPU/1000,
-							podCounter[ipAddress].reservedMemory/1024/1024/1024,
-							timeToLive,
-							//resource.NewQuantity(int64(podCounter[ipAddress].reservedMemory), resource.DecimalSI).ScaledValue(resource.Giga),
+							node.Pods,
+							node.RequestCPU,
+							node.RequestGiB,
 						)
 					} else { // Normal AWS
-						fmt.Printf("%s%6s %6s old %s%*s%s is %s%9s%s in %s%10s%s & %d taints - %2s CPUs %3v Gi (%12s) %14s, %d Labels %d Vol%s %s\n",
+						fmt.Printf("%s%6s %6s old %s%*s%s is %s%9s%s in %s%10s%s & %d taints - %2s CPUs %3v Gi (%12s) %14s, %d Labels %d Vol%s\n",
 							nodeColorStripe,
-							warnings,
-							secDiff(time.Now().Unix()-create),
+							node.Warnings,
+							node.Age,
 							workloadColor,
 							nameWidth,
-							hostName,
+							node.Name,
 							nodeColorStripe,
 							capacityTypeColor,
-							labels["eks.amazonaws.com/capacityType"],
+							node.CapacityType,
 							nodeColorStripe,
 							azColor,
-							labels["failure-domain.beta.kubernetes.io/zone"],
+							node.Zone,
 							nodeColorStripe,
-							len(nodes.Items[i].Spec.Taints),
-							capacity.Cpu().String(),
-							totalMemorySize.Value()/1024/1024/1024,
-							instanceType,
-							ipAddress,
-							len(nodes.Items[i].Labels),
-							len(nodes.Items[i].Status.VolumesAttached),
+							len(node.Taints),
+							node.CPUs,
+							node.MemoryGiB,
+							node.InstanceType,
+							node.IP,
+							node.Labels,
+							node.Volumes,
 							normalColor,
-							timeToLive,
 						)
 					}
 				} else { // We're on prem
 					if printPodDetails {
 						fmt.Printf("%s%s %s%16s%s is %6s old has %d taints - %2s CPUs %3v Gi - %14s, %d Labels %d, Vols%s. %3d Pods w/req: %3d CPUs, %3d Mem\n",
 							nodeColorStripe,
-							warnings,
+							node.Warnings,
 							workloadColor,
-							hostName,
+							node.Name,
 							nodeColorStripe,
-							secDiff(time.Now().Unix()-create),
-							len(nodes.Items[i].Spec.Taints),
-							capacity.Cpu().String(),
-							totalMemorySize.Value()/1024/1024/1024,
-							ipAddress,
-							len(nodes.Items[i].Labels),
-							len(nodes.Items[i].Status.VolumesAttached),
+							node.Age,
+							len(node.Taints),
+							node.CPUs,
+							node.MemoryGiB,
+							node.IP,
+							node.Labels,
+							node.Volumes,
 							nodeColorStripe,
-							podCounter[ipAddress].count,
-							podCounter[ipAddress].reservedCPU/1000,
-							podCounter[ipAddress].reservedMemory/1024/1024/1024,
+							node.Pods,
+							node.RequestCPU,
+							node.RequestGiB,
 						)
 					} else {
 						fmt.Printf("%s%s %s%16s%s is %6s old has %d taints - %2s CPUs %3v Gi - %14s, %d Labels, %d Vols %s.\n",
 							nodeColorStripe,
-							warnings,
+							node.Warnings,
 							workloadColor,
-							hostName,
+							node.Name,
 							nodeColorStripe,
-							secDiff(time.Now().Unix()-create),
-							len(nodes.Items[i].Spec.Taints),
-							capacity.Cpu().String(),
-							totalMemorySize.Value()/1024/1024/1024,
-							ipAddress,
-							len(nodes.Items[i].Labels),
-							len(nodes.Items[i].Status.VolumesAttached),
+							node.Age,
+							len(node.Taints),
+							node.CPUs,
+							node.MemoryGiB,
+							node.IP,
+							node.Labels,
+							node.Volumes,
 							nodeColorStripe,
 						)
 					}
 				}
-				// beta.kubernetes.io/instance-type
-				// failure-domain.beta.kubernetes.io/zone
-
-				//		if len(nodes.Items[i].Labels) > 0 {
-				//			for j := 0; j < len(nodes.Items[i].Spec); j++ {
-
-				//			}
-				//		}
-				for j := 0; j < len(nodes.Items[i].Spec.Taints); j++ {
-					taint := nodes.Items[i].Spec.Taints[j]
-					if taint.Key != "workload_type" &&
-						taint.Key != "DeletionCandidateOfClusterAutoscaler" &&
-						taint.Key != "node.kubernetes.io/disk-pressure" &&
-						taint.Key != "eks.amazonaws.com/compute-type" {
-						fmt.Printf("%s    | Taint %s => %s \n", taintColor, taint.Key, taint.Value)
-					}
+				for _, taint := range node.Taints {
+					fmt.Printf("%s    | Taint %s \n", taintColor, taint)
 				}
 			}
 		} // End printNodeSummary
+
+		if len(whatIfCatalog) > 0 {
+			printWhatIf(clusterDetails[clusterNum], whatIfCatalog)
+		}
+
+		if imageHygiene {
+			var registries []string
+			if allowedRegistries != "" {
+				registries = strings.Split(allowedRegistries, ",")
+			}
+			printImageHygiene(checkImageHygiene(clusterDetails[clusterNum], registries, largeImageMB*1024*1024), imageHygieneByWorkload)
+		}
+
+		if pricingFile != "" {
+			printCostReport(costReports[clusterNum], teamLabel)
+		}
 	}
+
 	if summarizeDeprecated && (len(summarizeDeprecatedString) > 0) {
 		fmt.Printf("\n%s===== %sDeprecations/Warnings%s =====%s\n", darkGray, errorColor, darkGray, normalColor)
 		fmt.Println(summarizeDeprecatedString)
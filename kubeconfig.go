@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// inClusterName is what a cluster is called when kube-helper runs inside it as a pod.
+const inClusterName = "in-cluster"
+
+// clusterTarget is one cluster to scan: the context it comes from and how to reach it.
+type clusterTarget struct {
+	context string
+	cluster string
+	config  *rest.Config
+}
+
+// loadingRules are the standard kubectl rules: every file in $KUBECONFIG merged, then
+// ~/.kube/config. An explicit path wins over both.
+func loadingRules(explicitPath string) *clientcmd.ClientConfigLoadingRules {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = explicitPath
+	return rules
+}
+
+// resolveClusterTargets picks the contexts to scan: the ones given with -c, every context
+// with --all-contexts, those matching --context-regex, or else the current context. Running in a
+// pod without a kubeconfig uses the service account instead.
+func resolveClusterTargets(explicitPath string, contexts []string, allContexts bool, contextRegex string) ([]clusterTarget, error) {
+	rules := loadingRules(explicitPath)
+	raw, err := rules.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(raw.Contexts) == 0 && explicitPath == "" && os.Getenv("KUBERNETES_SERVICE_HOST") != "" {
+		config, err := rest.InClusterConfig()
+		if err != nil {
+			return nil, err
+		}
+		return []clusterTarget{{context: inClusterName, cluster: inClusterName, config: config}}, nil
+	}
+
+	var names []string
+	switch {
+	case allContexts || contextRegex != "":
+		var re *regexp.Regexp
+		if contextRegex != "" {
+			if re, err = regexp.Compile(contextRegex); err != nil {
+				return nil, fmt.Errorf("context regex: %v", err)
+			}
+		}
+		for name := range raw.Contexts {
+			if re == nil || re.MatchString(name) {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		if len(names) == 0 {
+			return nil, fmt.Errorf("no contexts match %q", contextRegex)
+		}
+	case len(contexts) > 0:
+		names = contexts
+	default:
+		if raw.CurrentContext == "" {
+			return nil, fmt.Errorf("no current context, pass one with -c")
+		}
+		names = []string{raw.CurrentContext}
+	}
+
+	var targets []clusterTarget
+	for _, name := range names {
+		overrides := &clientcmd.ConfigOverrides{CurrentContext: name}
+		config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, overrides).ClientConfig()
+		if err != nil {
+			return nil, fmt.Errorf("context %s: %v", name, err)
+		}
+		target := clusterTarget{context: name, config: config}
+		if context, ok := raw.Contexts[name]; ok {
+			target.cluster = context.Cluster
+		}
+		targets = append(targets, target)
+	}
+	return targets, nil
+}
+
+// homeClusterConfig reaches the cluster kube-helper belongs to rather than one it scans: the
+// service account when it runs in a pod without a kubeconfig, or else the current context.
+func homeClusterConfig(explicitPath string) (*rest.Config, error) {
+	rules := loadingRules(explicitPath)
+	raw, err := rules.Load()
+	if err != nil {
+		return nil, err
+	}
+	if len(raw.Contexts) == 0 && explicitPath == "" && os.Getenv("KUBERNETES_SERVICE_HOST") != "" {
+		return rest.InClusterConfig()
+	}
+	if raw.CurrentContext == "" {
+		return nil, fmt.Errorf("no current context to reach the cluster with")
+	}
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, &clientcmd.ConfigOverrides{}).ClientConfig()
+}
+
+// describeCluster names a cluster for progress output: the context, the kubeconfig cluster
+// when it is called something else, and the API server version.
+func describeCluster(target clusterTarget, version string) string {
+	description := target.context
+	if target.cluster != "" && target.cluster != target.context {
+		description += " (" + target.cluster + ")"
+	}
+	if version != "" {
+		description += " " + version
+	}
+	return description
+}
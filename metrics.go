@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+var (
+	namespacePodsDesc = prometheus.NewDesc("kube_helper_namespace_pods",
+		"Pods per namespace by derived status.", []string{"cluster", "namespace", "status"}, nil)
+	namespaceCPUDesc = prometheus.NewDesc("kube_helper_namespace_cpu_requests_millicores",
+		"Requested CPU per namespace in millicores.", []string{"cluster", "namespace"}, nil)
+	namespaceRAMDesc = prometheus.NewDesc("kube_helper_namespace_memory_requests_bytes",
+		"Requested memory per namespace in bytes.", []string{"cluster", "namespace"}, nil)
+	emptyNamespacesDesc = prometheus.NewDesc("kube_helper_empty_namespaces",
+		"Namespaces with no pods, virtual services, ingresses, config maps, secrets or cron jobs.", []string{"cluster"}, nil)
+	imagesDesc = prometheus.NewDesc("kube_helper_images",
+		"Distinct images running in the cluster.", []string{"cluster"}, nil)
+	imagePodsDesc = prometheus.NewDesc("kube_helper_image_containers",
+		"Containers running each image.", []string{"cluster", "image"}, nil)
+	workloadPodsDesc = prometheus.NewDesc("kube_helper_workload_pods",
+		"Pods per workload.", []string{"cluster", "namespace", "workload", "kind"}, nil)
+	deploymentDesiredDesc = prometheus.NewDesc("kube_helper_deployment_spec_replicas",
+		"Replicas a Deployment asks for.", []string{"cluster", "namespace", "deployment"}, nil)
+	deploymentReadyDesc = prometheus.NewDesc("kube_helper_deployment_status_replicas_ready",
+		"Ready replicas of a Deployment.", []string{"cluster", "namespace", "deployment"}, nil)
+	deploymentAvailableDesc = prometheus.NewDesc("kube_helper_deployment_status_replicas_available",
+		"Available replicas of a Deployment.", []string{"cluster", "namespace", "deployment"}, nil)
+	nodesDesc = prometheus.NewDesc("kube_helper_nodes",
+		"Nodes by instance type, zone and capacity type.", []string{"cluster", "instance_type", "zone", "capacity_type"}, nil)
+	stuckHelmDesc = prometheus.NewDesc("kube_helper_helm_releases_stuck",
+		"Helm release secrets left in pending-update.", []string{"cluster", "namespace"}, nil)
+	deprecatedDesc = prometheus.NewDesc("kube_helper_deprecated_objects",
+		"Objects still served from deprecated APIs.", []string{"cluster", "api"}, nil)
+	lastScanDesc = prometheus.NewDesc("kube_helper_last_scan_timestamp_seconds",
+		"When the scan behind these metrics finished.", nil, nil)
+	scanSuccessDesc = prometheus.NewDesc("kube_helper_last_scan_success",
+		"Whether the last scan of the cluster worked; when it did not, the metrics are from the one before.", []string{"cluster"}, nil)
+	lastGoodScanDesc = prometheus.NewDesc("kube_helper_last_successful_scan_timestamp_seconds",
+		"When the cluster was last scanned successfully.", []string{"cluster"}, nil)
+)
+
+// scanCollector turns the latest cached scan into metrics on every scrape, so series for
+// namespaces or images that went away disappear with the next scan.
+type scanCollector struct {
+	cache *scanCache
+}
+
+func (c scanCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range []*prometheus.Desc{namespacePodsDesc, namespaceCPUDesc, namespaceRAMDesc, emptyNamespacesDesc, imagesDesc,
+		imagePodsDesc, workloadPodsDesc, deploymentDesiredDesc, deploymentReadyDesc, deploymentAvailableDesc, nodesDesc, stuckHelmDesc, deprecatedDesc, lastScanDesc, scanSuccessDesc, lastGoodScanDesc} {
+		ch <- desc
+	}
+}
+
+func (c scanCollector) Collect(ch chan<- prometheus.Metric) {
+	clusters, scanned := c.cache.get()
+	gauge := func(desc *prometheus.Desc, value float64, labels ...string) {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, value, labels...)
+	}
+
+	gauge(lastScanDesc, float64(scanned.Unix()))
+	succeeded, lastGood := c.cache.health()
+	for name, ok := range succeeded {
+		success := 0.0
+		if ok {
+			success = 1
+		}
+		gauge(scanSuccessDesc, success, name)
+		gauge(lastGoodScanDesc, float64(lastGood[name].Unix()), name)
+	}
+	for _, cluster := range clusters {
+		var images = make(map[string]imageInfo)
+		var emptyNamespaces int
+		for _, ns := range cluster.namespaces {
+			for i, count := range ns.statusSummary.counts() {
+				gauge(namespacePodsDesc, float64(count), cluster.name, ns.name, podStatuses[i])
+			}
+			gauge(namespaceCPUDesc, float64(ns.totalCPURequest), cluster.name, ns.name)
+			gauge(namespaceRAMDesc, float64(ns.totalRAMRequest), cluster.name, ns.name)
+
+			for _, deployment := range ns.deployments {
+				if deployment.name == "" {
+					continue
+				}
+				gauge(workloadPodsDesc, float64(deployment.count), cluster.name, ns.name, deployment.name, deployment.kind)
+			}
+			for _, replicas := range ns.replicas {
+				gauge(deploymentDesiredDesc, float64(replicas.desired), cluster.name, ns.name, replicas.name)
+				gauge(deploymentReadyDesc, float64(replicas.ready), cluster.name, ns.name, replicas.name)
+				gauge(deploymentAvailableDesc, float64(replicas.available), cluster.name, ns.name, replicas.name)
+			}
+			for _, info := range ns.images {
+				mergeImage(images, info)
+			}
+
+			stuck := 0
+			for _, secret := range ns.secrets {
+				if helmPending(secret) {
+					stuck++
+				}
+			}
+			if stuck > 0 {
+				gauge(stuckHelmDesc, float64(stuck), cluster.name, ns.name)
+			}
+
+			if len(ns.pods)+len(ns.virtualServices)+len(ns.ingresses)+len(ns.configMaps)+len(ns.secrets)+len(ns.cronJobs) == 0 {
+				emptyNamespaces++
+			}
+		}
+		gauge(emptyNamespacesDesc, float64(emptyNamespaces), cluster.name)
+
+		gauge(imagesDesc, float64(len(images)), cluster.name)
+		for _, info := range images {
+			gauge(imagePodsDesc, float64(info.count), cluster.name, info.imageKey)
+		}
+
+		type nodeKey struct{ instanceType, zone, capacityType string }
+		var nodes = make(map[nodeKey]int)
+		for _, node := range cluster.nodeList {
+			nodes[nodeKey{node.GetLabels()["beta.kubernetes.io/instance-type"], zoneOf(node), capacityTypeOf(node)}]++
+		}
+		for key, count := range nodes {
+			gauge(nodesDesc, float64(count), cluster.name, key.instanceType, key.zone, key.capacityType)
+		}
+
+		for api, count := range cluster.deprecations {
+			gauge(deprecatedDesc, float64(count), cluster.name, api)
+		}
+	}
+}
+
+func zoneOf(node v1.Node) string {
+	labels := node.GetLabels()
+	if zone, ok := labels["topology.kubernetes.io/zone"]; ok {
+		return zone
+	}
+	return labels["failure-domain.beta.kubernetes.io/zone"]
+}
+
+// countDeprecations counts the same objects checkDeprecations warns about, across the
+// namespaces the filter lets through. APIs the cluster no longer serves count as zero.
+func countDeprecations(c *kubernetes.Clientset, filter scanFilter) map[string]int {
+	var counts = make(map[string]int)
+	if ingresses, err := c.NetworkingV1beta1().Ingresses("").List(context.TODO(), filter.listOptions("")); err == nil {
+		counts["networking.k8s.io/v1beta1 Ingress"] = 0
+		for _, ingress := range ingresses.Items {
+			if filter.includesNamespace(ingress.Namespace) {
+				counts["networking.k8s.io/v1beta1 Ingress"]++
+			}
+		}
+	}
+	if cronJobs, err := c.BatchV1beta1().CronJobs("").List(context.TODO(), filter.listOptions("")); err == nil {
+		counts["batch/v1beta1 CronJob"] = 0
+		for _, cronJob := range cronJobs.Items {
+			if filter.includesNamespace(cronJob.Namespace) {
+				counts["batch/v1beta1 CronJob"]++
+			}
+		}
+	}
+	return counts
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// Scheduling constraint categories, in the order the Pending Pods summary lists them.
+const (
+	pendingCPU       = "Insufficient cpu"
+	pendingMemory    = "Insufficient memory"
+	pendingTaint     = "Untolerated taint"
+	pendingAffinity  = "Node affinity/selector"
+	pendingPVC       = "PVC binding"
+	pendingTooMany   = "Too many pods"
+	pendingScheduled = "Scheduled, containers not started"
+	pendingOther     = "Other"
+)
+
+var pendingCategories = []string{pendingCPU, pendingMemory, pendingTaint, pendingAffinity, pendingPVC, pendingTooMany, pendingScheduled, pendingOther}
+
+var schedulerPatterns = []struct {
+	category string
+	pattern  *regexp.Regexp
+}{
+	{pendingCPU, regexp.MustCompile(`Insufficient cpu`)},
+	{pendingMemory, regexp.MustCompile(`Insufficient memory`)},
+	{pendingTaint, regexp.MustCompile(`had (untolerated )?taint`)},
+	{pendingAffinity, regexp.MustCompile(`didn't match (Pod's )?node (affinity|selector)|node\(s\) didn't match node selector`)},
+	{pendingPVC, regexp.MustCompile(`(?i)persistentvolumeclaim|volume node affinity conflict|didn't find available persistent volumes|volume binding`)},
+	{pendingTooMany, regexp.MustCompile(`Too many pods`)},
+}
+
+// schedulingInfo is why a pending pod is still pending.
+type schedulingInfo struct {
+	reason     string
+	message    string
+	categories []string
+}
+
+// categorizeSchedulerMessage turns a FailedScheduling message like "0/12 nodes are available:
+// 3 Insufficient cpu, 2 node(s) had taint {...}" into categories. The preemption part is about
+// what the scheduler tried next and is left out.
+func categorizeSchedulerMessage(message string) []string {
+	var categories []string
+	if i := strings.Index(message, "preemption:"); i >= 0 {
+		message = message[:i]
+	}
+	for _, p := range schedulerPatterns {
+		if p.pattern.MatchString(message) {
+			categories = append(categories, p.category)
+		}
+	}
+	if len(categories) == 0 {
+		categories = append(categories, pendingOther)
+	}
+	return categories
+}
+
+// schedulingStatus reads the PodScheduled condition of a pending pod, falling back to the most
+// recent FailedScheduling event when the condition has no message.
+func schedulingStatus(pod v1.Pod, events map[string]v1.Event) schedulingInfo {
+	var info schedulingInfo
+	if pod.Status.Phase != v1.PodPending {
+		return info
+	}
+
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type != v1.PodScheduled {
+			continue
+		}
+		if condition.Status == v1.ConditionTrue {
+			info.reason = "Scheduled"
+			info.categories = []string{pendingScheduled}
+			return info
+		}
+		info.reason = condition.Reason
+		info.message = condition.Message
+	}
+
+	if event, ok := events[pod.Namespace+"/"+pod.Name]; ok && info.message == "" {
+		info.reason = event.Reason
+		info.message = event.Message
+	}
+	info.categories = categorizeSchedulerMessage(info.message)
+	return info
+}
+
+// latestFailedScheduling keeps the newest FailedScheduling event per namespace/pod.
+func latestFailedScheduling(events []v1.Event) map[string]v1.Event {
+	var latest = make(map[string]v1.Event)
+	for _, event := range events {
+		if event.Reason != "FailedScheduling" || event.InvolvedObject.Kind != "Pod" {
+			continue
+		}
+		key := event.InvolvedObject.Namespace + "/" + event.InvolvedObject.Name
+		if current, ok := latest[key]; !ok || coreEventTime(event).After(coreEventTime(current)) {
+			latest[key] = event
+		}
+	}
+	return latest
+}
+
+func printPendingPods(cluster clusterDetail) {
+	var goodColor = colorString(32, false)
+	var errorColor = colorString(31, false)
+	var normalColor = colorString(37, false)
+	var darkGray = colorString(30, false)
+
+	type pendingPod struct {
+		namespace string
+		pod       podInfo
+	}
+	var pending []pendingPod
+	var counts = make(map[string]int)
+	for _, ns := range cluster.namespaces {
+		for _, pod := range ns.pods {
+			if pod.status != "Pending" {
+				continue
+			}
+			pending = append(pending, pendingPod{namespace: ns.name, pod: pod})
+			for _, category := range pod.scheduling.categories {
+				counts[category]++
+			}
+		}
+	}
+	if len(pending) == 0 {
+		return
+	}
+	sort.Slice(pending, func(i, j int) bool {
+		if pending[i].namespace != pending[j].namespace {
+			return pending[i].namespace < pending[j].namespace
+		}
+		return pending[i].pod.name < pending[j].pod.name
+	})
+
+	fmt.Printf("\n%s===== %sPending Pods%s =====%s\n", darkGray, errorColor, darkGray, normalColor)
+	for _, category := range pendingCategories {
+		if counts[category] > 0 {
+			fmt.Printf("\t%s%4d%s pods blocked by %s\n", goodColor, counts[category], normalColor, category)
+		}
+	}
+	fmt.Println()
+	for _, p := range pending {
+		fmt.Printf(" %s%s/%s%s: %s\n", darkGray, p.namespace, normalColor, p.pod.name, strings.Join(p.pod.scheduling.categories, ", "))
+		if p.pod.scheduling.message != "" {
+			fmt.Printf("     %s%s%s\n", darkGray, p.pod.scheduling.message, normalColor)
+		}
+	}
+	fmt.Println()
+}
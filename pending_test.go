@@ -0,0 +1,46 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestLatestFailedScheduling(t *testing.T) {
+	start := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	failed := func(pod, message string, set func(*v1.Event)) v1.Event {
+		event := v1.Event{Reason: "FailedScheduling", Message: message,
+			InvolvedObject: v1.ObjectReference{Kind: "Pod", Namespace: "web", Name: pod}}
+		set(&event)
+		return event
+	}
+	at := func(minutes int) time.Time { return start.Add(time.Duration(minutes) * time.Minute) }
+
+	events := []v1.Event{
+		// Recorded through events.k8s.io: only EventTime, or a series, is set.
+		failed("api-1", "newest by series", func(e *v1.Event) {
+			e.EventTime = metav1.NewMicroTime(at(0))
+			e.Series = &v1.EventSeries{Count: 4, LastObservedTime: metav1.NewMicroTime(at(30))}
+		}),
+		failed("api-1", "older by event time", func(e *v1.Event) { e.EventTime = metav1.NewMicroTime(at(10)) }),
+		failed("api-1", "older by last timestamp", func(e *v1.Event) { e.LastTimestamp = metav1.NewTime(at(20)) }),
+
+		failed("api-2", "older by last timestamp", func(e *v1.Event) { e.LastTimestamp = metav1.NewTime(at(5)) }),
+		failed("api-2", "newest by event time", func(e *v1.Event) { e.EventTime = metav1.NewMicroTime(at(15)) }),
+
+		failed("api-3", "other reason", func(e *v1.Event) { e.Reason = "Scheduled"; e.EventTime = metav1.NewMicroTime(at(40)) }),
+	}
+	latest := latestFailedScheduling(events)
+
+	want := map[string]string{"web/api-1": "newest by series", "web/api-2": "newest by event time"}
+	if len(latest) != len(want) {
+		t.Errorf("%d pods with scheduling failures, want %d", len(latest), len(want))
+	}
+	for pod, message := range want {
+		if got := latest[pod].Message; got != message {
+			t.Errorf("%s: latest failure %q, want %q", pod, got, message)
+		}
+	}
+}
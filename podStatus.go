@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// Pod statuses, in the order the status matrix shows them.
+var podStatuses = []string{"Running", "NotReady", "Pending", "Terminating", "Completed", "Failed", "Unknown", "Other"}
+
+// podStatus derives what kubectl would call the pod: a deletionTimestamp wins over the phase,
+// Succeeded is Completed, and Running pods are only Running once they are Ready.
+func podStatus(pod v1.Pod) string {
+	if pod.DeletionTimestamp != nil {
+		return "Terminating"
+	}
+	switch pod.Status.Phase {
+	case v1.PodSucceeded:
+		return "Completed"
+	case v1.PodFailed:
+		return "Failed"
+	case v1.PodUnknown:
+		return "Unknown"
+	case v1.PodPending:
+		return "Pending"
+	case v1.PodRunning:
+		for _, condition := range pod.Status.Conditions {
+			if condition.Type == v1.PodReady && condition.Status == v1.ConditionTrue {
+				return "Running"
+			}
+		}
+		return "NotReady"
+	}
+	return "Other"
+}
+
+func (s *podStatusSummary) add(status string) {
+	switch status {
+	case "Running":
+		s.running++
+	case "NotReady":
+		s.notReady++
+	case "Pending":
+		s.pending++
+	case "Terminating":
+		s.terminating++
+	case "Completed":
+		s.completed++
+	case "Failed":
+		s.failed++
+	case "Unknown":
+		s.unknown++
+	default:
+		s.other++
+	}
+}
+
+func (s podStatusSummary) plus(o podStatusSummary) podStatusSummary {
+	return podStatusSummary{
+		running:      s.running + o.running,
+		notReady:     s.notReady + o.notReady,
+		pending:      s.pending + o.pending,
+		terminating:  s.terminating + o.terminating,
+		completed:    s.completed + o.completed,
+		failed:       s.failed + o.failed,
+		unknown:      s.unknown + o.unknown,
+		crashlooping: s.crashlooping + o.crashlooping,
+		other:        s.other + o.other,
+	}
+}
+
+// counts returns the summary in podStatuses order.
+func (s podStatusSummary) counts() []int {
+	return []int{s.running, s.notReady, s.pending, s.terminating, s.completed, s.failed, s.unknown, s.other}
+}
+
+func (s podStatusSummary) total() int {
+	total := 0
+	for _, count := range s.counts() {
+		total += count
+	}
+	return total
+}
+
+// printStatusMatrix prints one row per namespace with pods and a cluster total underneath.
+func printStatusMatrix(cluster clusterDetail) {
+	var goodColor = colorString(32, false)
+	var errorColor = colorString(31, false)
+	var warningColor = colorString(33, false)
+	var normalColor = colorString(37, false)
+	var darkGray = colorString(30, false)
+
+	var names []string
+	var total podStatusSummary
+	width := len("Total")
+	for name, ns := range cluster.namespaces {
+		if ns.statusSummary.total() == 0 {
+			continue
+		}
+		names = append(names, name)
+		total = total.plus(ns.statusSummary)
+		if len(name) > width {
+			width = len(name)
+		}
+	}
+	sort.Strings(names)
+
+	cell := func(status string, count int) string {
+		color := goodColor
+		switch {
+		case count == 0:
+			color = darkGray
+		case status == "Pending" || status == "Failed" || status == "Unknown" || status == "CrashLoop":
+			color = errorColor
+		case status == "NotReady" || status == "Terminating" || status == "Other":
+			color = warningColor
+		}
+		return fmt.Sprintf("%s%11d%s", color, count, normalColor)
+	}
+	row := func(name string, summary podStatusSummary) {
+		fmt.Printf(" %-*s", width, name)
+		for i, count := range summary.counts() {
+			fmt.Print(cell(podStatuses[i], count))
+		}
+		fmt.Printf("%s\n", cell("CrashLoop", summary.crashlooping))
+	}
+
+	fmt.Printf("\n%s===== %sPod Status Matrix (%s)%s =====%s\n", darkGray, goodColor, cluster.name, darkGray, normalColor)
+	fmt.Printf(" %-*s", width, "")
+	for _, status := range append(append([]string{}, podStatuses...), "CrashLoop") {
+		fmt.Printf("%11s", status)
+	}
+	fmt.Println()
+	for _, name := range names {
+		row(name, cluster.namespaces[name].statusSummary)
+	}
+	row("Total", total)
+	fmt.Println()
+}
@@ -4,7 +4,7 @@ import (
 	"fmt"
 )
 
-func printNameSpaceDetails(ns nameSpaceDetail, nsTotalCPU int64, nsTotalRAM int64) {
+func printNameSpaceDetails(ns nameSpaceDetail, nsTotalCPU int64, nsTotalRAM int64, rank ranking) {
 	const dark = 30
 	const light = 37
 	const red = 31
@@ -25,37 +25,39 @@ func printNameSpaceDetails(ns nameSpaceDetail, nsTotalCPU int64, nsTotalRAM int6
 	// check for bad helm secrets:
 	for s := 0; s < len(ns.secrets); s++ {
 		secret := ns.secrets[s]
-		if secret.data.GetObjectMeta().GetLabels()["status"] == "pending-update" {
-			fmt.Printf("%sBad Helm Secret %s: %s%s\n", errorColor, secret.data.GetName(), secret.data.GetObjectMeta().GetLabels()["status"], normalColor)
+		if helmPending(secret) {
+			fmt.Printf("%sBad Helm Secret %s: %s%s\n", errorColor, secret.data.GetName(), settings.HelmPendingLabel, normalColor)
 		}
 	}
 
+	ns.pods = rank.sortPods(ns.pods)
 	for p := 0; p < len(ns.pods); p++ {
 		//		podMemory = append(podMemory, float32(ns.pods[p].reservedMemory/1024/1024/1024))
 		//		podCPU = append(podMemory, float32(ns.pods[p].reservedCPU))
 
-		switch ns.pods[p].Phase {
+		switch ns.pods[p].status {
+		case "Running", "Completed":
+			statusColor = goodColor
+		case "Failed", "Unknown":
+			statusColor = errorColor
 		case "Pending":
-			// XXX Put reason back when we have it.
-			//fmt.Printf("%s PENDING: %s in the %s namespace.%s \n", warningColor, ns.pods[p].name, ns.name, normalColor) //, pods.Items[i].Status.Conditions[0].Reason, pods.Items[i].Status.Conditions[0].Message)
+			fmt.Printf("%s PENDING: %s in the %s namespace: %s %s%s\n", warningColor, ns.pods[p].name, ns.name, ns.pods[p].scheduling.reason, ns.pods[p].scheduling.message, normalColor)
 			statusColor = errorColor
 		default:
 			statusColor = warningColor
 		}
 
 		useColor := goodColor
-		if ns.pods[p].reservedMemory/1024/1024/512 > 1 {
-			useColor = warningColor
-		} else if ns.pods[p].reservedCPU/1024/1024/1024 > 2 {
+		if heavyPod(ns.pods[p]) {
 			useColor = warningColor
 		}
 
-		fmt.Printf("%s%12s %s %48s - %64s - %s %5dm vCPU  %4dMB MEM\n",
+		fmt.Printf("%s%12s %s %s - %s - %s %5dm vCPU  %4dMB MEM\n",
 			statusColor,
-			ns.pods[p].Phase,
+			ns.pods[p].status,
 			normalColor,
-			ns.name,
-			ns.pods[p].name,
+			fitColumn(ns.name, 48),
+			fitColumn(ns.pods[p].name, 64),
 			useColor,
 			ns.pods[p].reservedCPU,
 			ns.pods[p].reservedMemory/1024/1024,
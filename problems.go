@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// Problem reasons, in the order the Problem Pods section lists them.
+var problemReasons = []string{
+	"Evicted",
+	"OOMKilled",
+	"CrashLoopBackOff",
+	"ImagePullBackOff",
+	"ErrImagePull",
+	"CreateContainerConfigError",
+	"Restarting",
+}
+
+// podDiagnosis is what the container statuses say about a pod. reason is empty for healthy pods.
+type podDiagnosis struct {
+	reason       string
+	container    string
+	lastReason   string
+	lastExitCode int32
+	lastRestart  time.Time
+}
+
+// diagnosePod classifies a pod from its container states. A crashlooping container whose last
+// run was OOMKilled is reported as OOMKilled, since that is the thing to fix.
+func diagnosePod(pod v1.Pod) podDiagnosis {
+	var diagnosis podDiagnosis
+
+	if pod.Status.Reason == "Evicted" {
+		diagnosis.reason = "Evicted"
+		return diagnosis
+	}
+
+	statuses := append([]v1.ContainerStatus{}, pod.Status.InitContainerStatuses...)
+	for _, status := range append(statuses, pod.Status.ContainerStatuses...) {
+		reason := ""
+		if status.State.Waiting != nil {
+			reason = status.State.Waiting.Reason
+		}
+		if status.State.Terminated != nil && status.State.Terminated.Reason == "OOMKilled" {
+			reason = "OOMKilled"
+		}
+
+		if last := status.LastTerminationState.Terminated; last != nil {
+			if last.FinishedAt.Time.After(diagnosis.lastRestart) {
+				diagnosis.lastRestart = last.FinishedAt.Time
+				diagnosis.lastReason = last.Reason
+				diagnosis.lastExitCode = last.ExitCode
+				if reason == "CrashLoopBackOff" && last.Reason == "OOMKilled" {
+					reason = "OOMKilled"
+				}
+			}
+		}
+
+		if moreSevere(reason, diagnosis.reason) {
+			diagnosis.reason = reason
+			diagnosis.container = status.Name
+		}
+	}
+
+	return diagnosis
+}
+
+// moreSevere reports whether reason a ranks above b in problemReasons. Reasons we do not know
+// about are never problems.
+func moreSevere(a string, b string) bool {
+	ia, okA := inSlice(problemReasons, a)
+	ib, okB := inSlice(problemReasons, b)
+	return okA && (!okB || ia < ib)
+}
+
+// problemOf adds the restart rule on top of the state based diagnosis: a pod that restarted at
+// least restartLimit times, most recently within window, is a problem even if it is up now.
+func problemOf(pod podInfo, restartLimit int32, window time.Duration) string {
+	if pod.diagnosis.reason != "" {
+		return pod.diagnosis.reason
+	}
+	if pod.RestartCount >= restartLimit && time.Since(pod.diagnosis.lastRestart) < window {
+		return "Restarting"
+	}
+	return ""
+}
+
+type problemGroup struct {
+	namespace    string
+	workload     string
+	pods         []string
+	maxRestarts  int32
+	lastReason   string
+	lastExitCode int32
+	lastRestart  time.Time
+}
+
+func printProblemPods(cluster clusterDetail, restartLimit int32, window time.Duration) {
+	var goodColor = colorString(32, false)
+	var errorColor = colorString(31, false)
+	var warningColor = colorString(33, false)
+	var normalColor = colorString(37, false)
+	var darkGray = colorString(30, false)
+
+	var groups = make(map[string]map[string]*problemGroup)
+	var total int
+	for _, ns := range cluster.namespaces {
+		for _, pod := range ns.pods {
+			reason := problemOf(pod, restartLimit, window)
+			if reason == "" {
+				continue
+			}
+			total++
+			if groups[reason] == nil {
+				groups[reason] = make(map[string]*problemGroup)
+			}
+			key := ns.name + "/" + workloadOf(pod)
+			group, ok := groups[reason][key]
+			if !ok {
+				group = &problemGroup{namespace: ns.name, workload: workloadOf(pod)}
+				groups[reason][key] = group
+			}
+			group.pods = append(group.pods, pod.name)
+			if pod.RestartCount > group.maxRestarts {
+				group.maxRestarts = pod.RestartCount
+			}
+			if pod.diagnosis.lastRestart.After(group.lastRestart) {
+				group.lastRestart = pod.diagnosis.lastRestart
+				group.lastReason = pod.diagnosis.lastReason
+				group.lastExitCode = pod.diagnosis.lastExitCode
+			}
+		}
+	}
+
+	if total == 0 {
+		return
+	}
+
+	fmt.Printf("\n%s===== %sProblem Pods%s =====%s\n", darkGray, errorColor, darkGray, normalColor)
+	for _, reason := range problemReasons {
+		if len(groups[reason]) == 0 {
+			continue
+		}
+		var keys []string
+		for key := range groups[reason] {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		color := errorColor
+		if reason == "Restarting" {
+			color = warningColor
+		}
+		fmt.Printf("\n %s%s%s\n", color, reason, normalColor)
+		for _, key := range keys {
+			group := groups[reason][key]
+			last := ""
+			if !group.lastRestart.IsZero() {
+				last = fmt.Sprintf(", last exit %s (%d) %s ago", group.lastReason, group.lastExitCode, secDiff(int64(time.Since(group.lastRestart).Seconds())))
+			}
+			fmt.Printf("   %s%3d%s x %s%s/%s%s: %d restarts%s\n", goodColor, len(group.pods), normalColor, darkGray, group.namespace, normalColor, group.workload, group.maxRestarts, last)
+			sort.Strings(group.pods)
+			fmt.Printf("         %s%s%s\n", darkGray, strings.Join(group.pods, " "), normalColor)
+		}
+	}
+	fmt.Println()
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// reportPrefix starts the name of every published report, so pruning only touches our own.
+const reportPrefix = "kube-helper-"
+
+// configMapLimit is the most a ConfigMap can hold.
+const configMapLimit = 1024 * 1024
+
+// serviceAccountNamespace holds the namespace of the pod kube-helper runs in.
+const serviceAccountNamespace = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+
+// jsonReport is the -o json document: the same views the serve command's API has.
+type jsonReport struct {
+	Generated  time.Time      `json:"generated"`
+	Clusters   []apiCluster   `json:"clusters"`
+	Namespaces []apiNamespace `json:"namespaces"`
+	Workloads  []apiWorkload  `json:"workloads"`
+	Nodes      []apiNode      `json:"nodes"`
+	Images     []apiImage     `json:"images"`
+	Costs      []costRow      `json:"costs,omitempty"`
+}
+
+func writeJSONReport(w io.Writer, clusters []clusterDetail, costs []costReport, vulnDB advisoryDB) error {
+	report := jsonReport{Generated: time.Now().UTC(), Namespaces: []apiNamespace{}, Workloads: []apiWorkload{}}
+	report.Clusters = clusterSummaries(clusters, report.Generated)
+	for _, cluster := range clusters {
+		report.Namespaces = append(report.Namespaces, namespaceSummaries(cluster)...)
+		for _, name := range sortedNamespaceNames(cluster.namespaces) {
+			report.Workloads = append(report.Workloads, workloadSummaries(cluster, cluster.namespaces[name])...)
+		}
+	}
+	report.Nodes = nodeUsage(clusters)
+	report.Images = imageUsage(clusters, vulnDB)
+	report.Costs = costRows(costs)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
+
+// reportExtensions maps each -o format to the extension and content type it is published with.
+var reportExtensions = map[string][2]string{
+	"json":     {"json", "application/json"},
+	"html":     {"html", "text/html; charset=utf-8"},
+	"markdown": {"md", "text/markdown; charset=utf-8"},
+	"csv":      {"csv", "text/csv; charset=utf-8"},
+}
+
+// reportSink is somewhere the report command publishes to.
+type reportSink interface {
+	publish(name, contentType string, data []byte) error
+}
+
+// newReportSink reads a --report-to destination:
+//
+//	configmap:[namespace/]name   a ConfigMap, in kube-helper's own namespace by default
+//	http://... or https://...    POSTed to an endpoint
+//	file:dir or dir              files in a directory, e.g. a mounted volume
+//
+// The ConfigMap lives in the cluster kube-helper runs in, or that of the current context, no
+// matter which contexts were scanned.
+func newReportSink(destination string, keep int, kubeconfigPath string) (reportSink, error) {
+	switch {
+	case destination == "":
+		return nil, fmt.Errorf("the report command needs --report-to")
+	case strings.HasPrefix(destination, "configmap:"):
+		target := strings.TrimPrefix(destination, "configmap:")
+		namespace, name := "", target
+		if parts := strings.SplitN(target, "/", 2); len(parts) == 2 {
+			namespace, name = parts[0], parts[1]
+		} else if data, err := os.ReadFile(serviceAccountNamespace); err == nil {
+			namespace = strings.TrimSpace(string(data))
+		} else {
+			return nil, fmt.Errorf("%s: give the namespace as configmap:namespace/name outside a pod", destination)
+		}
+		config, err := homeClusterConfig(kubeconfigPath)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", destination, err)
+		}
+		clientset, err := kubernetes.NewForConfig(config)
+		if err != nil {
+			return nil, err
+		}
+		return configMapSink{clientset: clientset, namespace: namespace, name: name, keep: keep}, nil
+	case strings.HasPrefix(destination, "http://"), strings.HasPrefix(destination, "https://"):
+		return httpSink{url: destination}, nil
+	}
+	return directorySink{dir: strings.TrimPrefix(destination, "file:"), keep: keep}, nil
+}
+
+// pruneReports returns the report names to delete so only the newest keep are left. Names sort
+// by time because they end in a UTC timestamp.
+func pruneReports(names []string, keep int) []string {
+	var reports []string
+	for _, name := range names {
+		if strings.HasPrefix(name, reportPrefix) {
+			reports = append(reports, name)
+		}
+	}
+	sort.Strings(reports)
+	if keep <= 0 || len(reports) <= keep {
+		return nil
+	}
+	return reports[:len(reports)-keep]
+}
+
+// configMapSink keeps the last reports as keys of one ConfigMap.
+type configMapSink struct {
+	clientset *kubernetes.Clientset
+	namespace string
+	name      string
+	keep      int
+}
+
+func (s configMapSink) publish(name, contentType string, data []byte) error {
+	configMaps := s.clientset.CoreV1().ConfigMaps(s.namespace)
+	configMap, err := configMaps.Get(context.TODO(), s.name, metav1.GetOptions{})
+	create := errors.IsNotFound(err)
+	if create {
+		configMap = &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: s.name, Namespace: s.namespace}}
+	} else if err != nil {
+		return err
+	}
+	if configMap.Data == nil {
+		configMap.Data = make(map[string]string)
+	}
+	configMap.Data[name] = string(data)
+
+	var names []string
+	for key := range configMap.Data {
+		names = append(names, key)
+	}
+	for _, old := range pruneReports(names, s.keep) {
+		delete(configMap.Data, old)
+	}
+	// Drop the oldest reports until the rest fit, but never the one just written.
+	for size(configMap.Data) > configMapLimit {
+		names = names[:0]
+		for key := range configMap.Data {
+			names = append(names, key)
+		}
+		old := pruneReports(names, 1)
+		if len(old) == 0 {
+			return fmt.Errorf("%s is %d bytes, more than a ConfigMap holds; publish to a volume instead", name, len(data))
+		}
+		delete(configMap.Data, old[0])
+	}
+
+	if create {
+		_, err = configMaps.Create(context.TODO(), configMap, metav1.CreateOptions{})
+	} else {
+		_, err = configMaps.Update(context.TODO(), configMap, metav1.UpdateOptions{})
+	}
+	return err
+}
+
+func size(data map[string]string) int {
+	total := 0
+	for key, value := range data {
+		total += len(key) + len(value)
+	}
+	return total
+}
+
+// directorySink writes each report as a file and keeps the last ones.
+type directorySink struct {
+	dir  string
+	keep int
+}
+
+func (s directorySink) publish(name, contentType string, data []byte) error {
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(s.dir, name), data, 0644); err != nil {
+		return err
+	}
+	files, err := os.ReadDir(s.dir)
+	if err != nil {
+		return err
+	}
+	var names []string
+	for _, file := range files {
+		names = append(names, file.Name())
+	}
+	for _, old := range pruneReports(names, s.keep) {
+		if err := os.Remove(filepath.Join(s.dir, old)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// httpSink POSTs each report; keeping history is up to the receiver.
+type httpSink struct {
+	url string
+}
+
+func (s httpSink) publish(name, contentType string, data []byte) error {
+	request, err := http.NewRequest(http.MethodPost, s.url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", contentType)
+	request.Header.Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+	client := http.Client{Timeout: 30 * time.Second}
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode/100 != 2 {
+		return fmt.Errorf("%s answered %s", s.url, response.Status)
+	}
+	return nil
+}
+
+// runReport renders the scan once and publishes it, for running on a schedule without a terminal.
+func runReport(sink reportSink, format string, render func(w io.Writer) error) error {
+	var buffer bytes.Buffer
+	if err := render(&buffer); err != nil {
+		return err
+	}
+	extension := reportExtensions[format]
+	name := reportPrefix + time.Now().UTC().Format("20060102T150405Z") + "." + extension[0]
+	if err := sink.publish(name, extension[1], buffer.Bytes()); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Published %s (%d bytes)\n", name, buffer.Len())
+	return nil
+}
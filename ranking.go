@@ -0,0 +1,207 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// sortByKeys are the values --sort-by takes.
+var sortByKeys = []string{"name", "cpu", "memory", "pods", "restarts", "age"}
+
+// rankItem is one row of a breakdown with everything it can be sorted by. What "pods" means
+// depends on the breakdown: containers for images, nodes for instance types.
+type rankItem struct {
+	key      string
+	cpu      int64
+	memory   int64
+	pods     int
+	restarts int32
+	age      int64
+}
+
+// ranking orders every breakdown the same way and keeps its first top rows.
+type ranking struct {
+	sortBy string
+	top    int
+}
+
+func newRanking(sortBy string, top int) (ranking, error) {
+	if top < 0 {
+		return ranking{}, fmt.Errorf("--top must not be negative")
+	}
+	for _, key := range sortByKeys {
+		if key == sortBy {
+			return ranking{sortBy: sortBy, top: top}, nil
+		}
+	}
+	return ranking{}, fmt.Errorf("unknown sort key %q, expected %s", sortBy, strings.Join(sortByKeys, ", "))
+}
+
+// less puts the biggest (or oldest) first, and falls back to the name so ties always come out
+// in the same order.
+func (r ranking) less(a, b rankItem) bool {
+	var x, y int64
+	switch r.sortBy {
+	case "cpu":
+		x, y = a.cpu, b.cpu
+	case "memory":
+		x, y = a.memory, b.memory
+	case "pods":
+		x, y = int64(a.pods), int64(b.pods)
+	case "restarts":
+		x, y = int64(a.restarts), int64(b.restarts)
+	case "age":
+		x, y = a.age, b.age
+	}
+	if x != y {
+		return x > y
+	}
+	return a.key < b.key
+}
+
+// rank returns the keys of items in order, cut to the top rows when --top is set.
+func (r ranking) rank(items []rankItem) []string {
+	sort.Slice(items, func(i, j int) bool { return r.less(items[i], items[j]) })
+	if r.top > 0 && len(items) > r.top {
+		items = items[:r.top]
+	}
+	var keys []string
+	for _, item := range items {
+		keys = append(keys, item.key)
+	}
+	return keys
+}
+
+func podRankItem(pod podInfo) rankItem {
+	return rankItem{key: pod.name, cpu: pod.reservedCPU, memory: pod.reservedMemory, pods: 1, restarts: pod.RestartCount, age: pod.podRunningTime}
+}
+
+// sortPods orders the pods of a namespace; --top applies to namespaces, not to their pods.
+func (r ranking) sortPods(pods []podInfo) []podInfo {
+	sorted := make([]podInfo, len(pods))
+	copy(sorted, pods)
+	sort.SliceStable(sorted, func(i, j int) bool { return r.less(podRankItem(sorted[i]), podRankItem(sorted[j])) })
+	return sorted
+}
+
+// sortedNamespaceNames is every namespace name in alphabetical order.
+func sortedNamespaceNames(namespaces map[string]nameSpaceDetail) []string {
+	var names []string
+	for name := range namespaces {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// namespaces ranks the namespaces of a cluster, only those running pods when withPods is set.
+func (r ranking) namespaces(cluster clusterDetail, withPods bool) []string {
+	var items []rankItem
+	for name, ns := range cluster.namespaces {
+		if withPods && len(ns.pods) == 0 {
+			continue
+		}
+		item := rankItem{key: name, cpu: ns.totalCPURequest, memory: ns.totalRAMRequest, pods: len(ns.pods)}
+		if !ns.created.IsZero() {
+			item.age = int64(time.Since(ns.created).Seconds())
+		}
+		for _, pod := range ns.pods {
+			item.restarts += pod.RestartCount
+		}
+		items = append(items, item)
+	}
+	return r.rank(items)
+}
+
+// workloads ranks the aggregated workloads. Restarts and age come from their pods: all
+// restarts, and the longest running pod.
+func (r ranking) workloads(clusters []clusterDetail, deployments map[string]deployInfo) []string {
+	var restarts = make(map[string]int32)
+	var ages = make(map[string]int64)
+	for _, cluster := range clusters {
+		for _, ns := range cluster.namespaces {
+			for _, pod := range ns.pods {
+				restarts[pod.ownerName] += pod.RestartCount
+				if pod.podRunningTime > ages[pod.ownerName] {
+					ages[pod.ownerName] = pod.podRunningTime
+				}
+			}
+		}
+	}
+	var items []rankItem
+	for name, info := range deployments {
+		items = append(items, rankItem{key: name, cpu: info.totalCPURequest, memory: info.totalRAMRequest, pods: info.count, restarts: restarts[name], age: ages[name]})
+	}
+	return r.rank(items)
+}
+
+// images ranks images by the containers running them; they have no requests or age of their own.
+func (r ranking) images(images map[string]imageInfo) []string {
+	var items []rankItem
+	for key, info := range images {
+		items = append(items, rankItem{key: key, pods: info.count})
+	}
+	return r.rank(items)
+}
+
+// instanceTypeSummaries ranks the rows of an Instance Type Breakdown.
+func (r ranking) instanceTypeSummaries(types []instanceTypeSummary) []instanceTypeSummary {
+	var byName = make(map[string]instanceTypeSummary)
+	var items []rankItem
+	for _, info := range types {
+		byName[info.Name] = info
+		items = append(items, rankItem{key: info.Name, cpu: info.VCPU, memory: info.MemoryGiB, pods: info.Count})
+	}
+	var ranked []instanceTypeSummary
+	for _, name := range r.rank(items) {
+		ranked = append(ranked, byName[name])
+	}
+	return ranked
+}
+
+// nodeSummaries puts the rows of a Node Breakdown in the order of nodes.
+func (r ranking) nodeSummaries(cluster clusterDetail, summaries []nodeSummary) []nodeSummary {
+	var byName = make(map[string]nodeSummary)
+	for _, summary := range summaries {
+		byName[summary.Name] = summary
+	}
+	var ranked []nodeSummary
+	for _, node := range r.nodes(cluster) {
+		ranked = append(ranked, byName[node.Name])
+	}
+	return ranked
+}
+
+// nodes orders the nodes of a cluster by what is requested on them, their pod count, the
+// restarts of their pods or their age.
+func (r ranking) nodes(cluster clusterDetail) []v1.Node {
+	var items []rankItem
+	var byName = make(map[string]rankItem)
+	for _, ns := range cluster.namespaces {
+		for _, pod := range ns.pods {
+			item := byName[pod.nodeName]
+			item.cpu += pod.reservedCPU
+			item.memory += pod.reservedMemory
+			item.pods++
+			item.restarts += pod.RestartCount
+			byName[pod.nodeName] = item
+		}
+	}
+	var nodes = make(map[string]v1.Node)
+	for _, node := range cluster.nodeList {
+		item := byName[node.Name]
+		item.key = node.Name
+		item.age = int64(time.Since(node.CreationTimestamp.Time).Seconds())
+		items = append(items, item)
+		nodes[node.Name] = node
+	}
+	var ranked []v1.Node
+	for _, name := range r.rank(items) {
+		ranked = append(ranked, nodes[name])
+	}
+	return ranked
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+// designWidth is the terminal width the fixed report columns (%48s, %64s, ...) were laid out for.
+const designWidth = 180
+
+// renderer decides how terminal output is styled: which escape codes it may emit and how wide
+// the fixed columns are. colorString and the node stripes all go through the active renderer.
+type renderer interface {
+	// color is an SGR color code (30-37, or 7 for reverse video), optionally bold.
+	color(code int, bold bool) string
+	// rgb is a 24-bit foreground on an optional 24-bit background.
+	rgb(fg rgb, bg *rgb, bold bool) string
+	// colorOn is an SGR color code on a 24-bit background.
+	colorOn(code int, bg rgb) string
+	// clear moves to the top left of a cleared screen.
+	clear() string
+	// column scales one of the fixed layout widths to the terminal.
+	column(width int) int
+}
+
+var output renderer = terminalRenderer{trueColor: true}
+
+// terminalRenderer writes ANSI colors, falling back to the 256-color palette when the terminal
+// does not do TrueColor.
+type terminalRenderer struct {
+	trueColor bool
+	columns   int
+}
+
+func (r terminalRenderer) color(code int, bold bool) string {
+	var makeBold int
+	if bold {
+		makeBold = 1
+	}
+	return fmt.Sprintf("\033[%d;%d;49m", makeBold, code)
+}
+
+func (r terminalRenderer) rgbParams(fg int, c rgb) string {
+	if r.trueColor {
+		return fmt.Sprintf("%d;2;%d;%d;%d", fg, c.red, c.green, c.blue)
+	}
+	return fmt.Sprintf("%d;5;%d", fg, ansi256(c))
+}
+
+func (r terminalRenderer) rgb(fg rgb, bg *rgb, bold bool) string {
+	params := r.rgbParams(38, fg)
+	if bg != nil {
+		params += ";" + r.rgbParams(48, *bg)
+	}
+	if bold {
+		params += ";1"
+	}
+	return "\033[" + params + "m"
+}
+
+func (r terminalRenderer) colorOn(code int, bg rgb) string {
+	return fmt.Sprintf("\033[%d;%sm", code, r.rgbParams(48, bg))
+}
+
+func (r terminalRenderer) clear() string {
+	return "\033[H\033[2J"
+}
+
+func (r terminalRenderer) column(width int) int {
+	return scaleColumn(width, r.columns)
+}
+
+// noColorRenderer keeps the terminal layout but emits no colors, for NO_COLOR.
+type noColorRenderer struct {
+	columns int
+}
+
+func (r noColorRenderer) color(code int, bold bool) string      { return "" }
+func (r noColorRenderer) rgb(fg rgb, bg *rgb, bold bool) string { return "" }
+func (r noColorRenderer) colorOn(code int, bg rgb) string       { return "" }
+func (r noColorRenderer) clear() string                         { return "\033[H\033[2J" }
+func (r noColorRenderer) column(width int) int                  { return scaleColumn(width, r.columns) }
+
+// plainRenderer emits no escape codes at all and keeps the original column widths, for output
+// going to a file, a pipe or a CI log.
+type plainRenderer struct{}
+
+func (r plainRenderer) color(code int, bold bool) string      { return "" }
+func (r plainRenderer) rgb(fg rgb, bg *rgb, bold bool) string { return "" }
+func (r plainRenderer) colorOn(code int, bg rgb) string       { return "" }
+func (r plainRenderer) clear() string                         { return "\n" }
+func (r plainRenderer) column(width int) int                  { return width }
+
+// scaleColumn shrinks a fixed width proportionally when the terminal is narrower than the
+// layout was designed for.
+func scaleColumn(width, columns int) int {
+	if columns <= 0 || columns >= designWidth {
+		return width
+	}
+	scaled := width * columns / designWidth
+	if scaled < 8 {
+		scaled = 8
+	}
+	return scaled
+}
+
+// ansi256 maps a 24-bit color to the nearest entry of the xterm 256-color palette.
+func ansi256(c rgb) int {
+	if c.red == c.green && c.green == c.blue {
+		switch {
+		case c.red < 8:
+			return 16
+		case c.red > 248:
+			return 231
+		}
+		return 232 + (c.red-8)*24/247
+	}
+	level := func(v int) int { return (v*5 + 127) / 255 }
+	return 16 + 36*level(c.red) + 6*level(c.green) + level(c.blue)
+}
+
+// terminalColumns reads $COLUMNS, then asks the terminal.
+func terminalColumns() int {
+	if columns, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && columns > 0 {
+		return columns
+	}
+	if width, _, err := term.GetSize(int(os.Stdout.Fd())); err == nil {
+		return width
+	}
+	return 0
+}
+
+// supportsTrueColor follows the COLORTERM convention most terminals use to advertise 24-bit color.
+func supportsTrueColor() bool {
+	colorTerm := strings.ToLower(os.Getenv("COLORTERM"))
+	return colorTerm == "truecolor" || colorTerm == "24bit"
+}
+
+// newRenderer picks the renderer for mode (auto, terminal, no-color or plain). In auto mode
+// output that is not a terminal is plain and NO_COLOR turns colors off. trueColor overrides
+// TrueColor detection when it is not nil.
+func newRenderer(mode string, trueColor *bool) (renderer, error) {
+	if mode == "auto" {
+		switch {
+		case !term.IsTerminal(int(os.Stdout.Fd())):
+			mode = "plain"
+		case os.Getenv("NO_COLOR") != "":
+			mode = "no-color"
+		default:
+			mode = "terminal"
+		}
+	}
+
+	switch mode {
+	case "terminal":
+		r := terminalRenderer{trueColor: supportsTrueColor(), columns: terminalColumns()}
+		if trueColor != nil {
+			r.trueColor = *trueColor
+		}
+		return r, nil
+	case "no-color":
+		return noColorRenderer{columns: terminalColumns()}, nil
+	case "plain":
+		return plainRenderer{}, nil
+	}
+	return nil, fmt.Errorf("unknown renderer %q, expected auto, terminal, no-color or plain", mode)
+}
+
+// fitColumn right-aligns s in one of the fixed layout widths, scaled to the terminal and
+// truncated when the scaled column is too narrow for it. Widths count runes, not bytes.
+func fitColumn(s string, width int) string {
+	s = truncateColumn(s, width)
+	return columnPadding(s, width) + s
+}
+
+// fitColumnLeft is fitColumn, left-aligned.
+func fitColumnLeft(s string, width int) string {
+	s = truncateColumn(s, width)
+	return s + columnPadding(s, width)
+}
+
+func columnPadding(s string, width int) string {
+	if pad := output.column(width) - utf8.RuneCountInString(s); pad > 0 {
+		return strings.Repeat(" ", pad)
+	}
+	return ""
+}
+
+func truncateColumn(s string, width int) string {
+	scaled := output.column(width)
+	if runes := []rune(s); scaled < width && len(runes) > scaled {
+		return string(runes[:scaled-1]) + "…"
+	}
+	return s
+}
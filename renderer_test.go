@@ -0,0 +1,35 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestFitColumnCountsRunes(t *testing.T) {
+	defer func(previous renderer) { output = previous }(output)
+	output = noColorRenderer{columns: designWidth / 2}
+
+	tests := []struct {
+		name string
+		s    string
+		want string
+	}{
+		{"fits", "café-api", "                café-api"},
+		{"truncated", "größenüberwachung-zähler-über-alles", "größenüberwachung-zähle…"},
+		{"padded by runes", "ñññññññññññññññññññññññ", " ñññññññññññññññññññññññ"},
+	}
+	for _, test := range tests {
+		got := fitColumn(test.s, 48)
+		if !utf8.ValidString(got) {
+			t.Errorf("%s: %q is not valid UTF-8", test.name, got)
+		}
+		if got != test.want {
+			t.Errorf("%s: %q, want %q", test.name, got, test.want)
+		}
+		left := fitColumnLeft(test.s, 48)
+		if n := utf8.RuneCountInString(left); n != 24 || strings.TrimRight(left, " ") != strings.TrimLeft(got, " ") {
+			t.Errorf("%s: left aligned %q is %d runes, want %q padded to 24", test.name, left, n, strings.TrimLeft(got, " "))
+		}
+	}
+}
@@ -0,0 +1,372 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// nodeSummary is one row of the Node Breakdown, whatever format it ends up in.
+type nodeSummary struct {
+	Warnings     string
+	Group        string
+	Name         string
+	Age          string
+	CapacityType string
+	Zone         string
+	CPUs         string
+	MemoryGiB    int64
+	InstanceType string
+	IP           string
+	Pods         int
+	RequestCPU   int64
+	RequestGiB   int64
+	Labels       int
+	Volumes      int
+	Taints       []string
+}
+
+// instanceTypeSummary is one row of the Instance Type Breakdown.
+type instanceTypeSummary struct {
+	Name      string
+	Count     int
+	VCPU      int64
+	MemoryGiB int64
+	Storage   int64
+}
+
+// summarizeNodes reads the Node and Instance Type Breakdowns out of a cluster, sorted by name.
+// Warnings use the same symbols as the terminal view.
+func summarizeNodes(cluster clusterDetail) ([]nodeSummary, []instanceTypeSummary) {
+	var podsOnNode = make(map[string]podInfo)
+	for _, ns := range cluster.namespaces {
+		for _, pod := range ns.pods {
+			podsOnNode[pod.nodeName] = podInfo{
+				count:          podsOnNode[pod.nodeName].count + 1,
+				reservedCPU:    podsOnNode[pod.nodeName].reservedCPU + pod.reservedCPU,
+				reservedMemory: podsOnNode[pod.nodeName].reservedMemory + pod.reservedMemory,
+			}
+		}
+	}
+
+	var nodes []nodeSummary
+	var typeBreakdown = make(map[string]instanceTypeSummary)
+	for _, node := range cluster.nodeList {
+		labels := node.GetLabels()
+		instanceType := labels["beta.kubernetes.io/instance-type"]
+		cores, _ := node.Status.Capacity.Cpu().AsInt64()
+		ram, _ := node.Status.Capacity.Memory().AsInt64()
+		storage, _ := node.Status.Capacity.Storage().AsInt64()
+		typeBreakdown[instanceType] = instanceTypeSummary{
+			Name:      instanceType,
+			Count:     typeBreakdown[instanceType].Count + 1,
+			VCPU:      cores,
+			MemoryGiB: ram / 1024 / 1024 / 1024,
+			Storage:   storage,
+		}
+
+		n := nodeSummary{
+			Group:        nodeGroupOf(node),
+			Name:         node.Name,
+			Age:          secDiff(time.Now().Unix() - node.CreationTimestamp.Unix()),
+			CapacityType: labels["eks.amazonaws.com/capacityType"],
+			Zone:         zoneOf(node),
+			CPUs:         node.Status.Capacity.Cpu().String(),
+			MemoryGiB:    ram / 1024 / 1024 / 1024,
+			InstanceType: instanceType,
+			Pods:         podsOnNode[node.Name].count,
+			RequestCPU:   podsOnNode[node.Name].reservedCPU / 1000,
+			RequestGiB:   podsOnNode[node.Name].reservedMemory / 1024 / 1024 / 1024,
+			Labels:       len(labels),
+			Volumes:      len(node.Status.VolumesAttached),
+		}
+		for _, address := range node.Status.Addresses {
+			if address.Type == "InternalIP" {
+				n.IP = address.Address
+			}
+		}
+		if node.Spec.Unschedulable {
+			n.Warnings += "🚫 "
+		}
+		for _, taint := range node.Spec.Taints {
+			switch taint.Key {
+			case settings.NodeGroupKey:
+			case "DeletionCandidateOfClusterAutoscaler":
+				n.Warnings += "🗑 "
+			case "node.kubernetes.io/not-ready":
+				n.Warnings += "✨ "
+				n.Taints = append(n.Taints, taint.Key+" => "+taint.Value)
+			case "node.kubernetes.io/disk-pressure":
+				n.Warnings += "💾 "
+			case "eks.amazonaws.com/compute-type":
+				n.InstanceType = "Fargate"
+			default:
+				n.Taints = append(n.Taints, taint.Key+" => "+taint.Value)
+			}
+		}
+		n.Warnings = strings.TrimSpace(n.Warnings)
+		nodes = append(nodes, n)
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Name < nodes[j].Name })
+
+	var types []instanceTypeSummary
+	for _, info := range typeBreakdown {
+		types = append(types, info)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i].Name < types[j].Name })
+	return nodes, types
+}
+
+// reportTable is one report table with its data already formatted, independent of layout.
+type reportTable struct {
+	name    string
+	title   string
+	columns []string
+	rows    [][]string
+}
+
+// tableRenderer draws report tables in one output format.
+type tableRenderer interface {
+	render(tables []reportTable) error
+}
+
+func itoa(i int64) string {
+	return strconv.FormatInt(i, 10)
+}
+
+func namespaceTable(clusters []clusterDetail, rank ranking) reportTable {
+	table := reportTable{name: "namespaces", title: "Namespace Summary",
+		columns: []string{"Cluster", "Namespace", "Pods", "Running", "Pending", "Failed", "VirtualServices", "ConfigMaps", "Secrets", "Images", "CPU (m)", "RAM (MB)"}}
+	for _, cluster := range clusters {
+		for _, name := range rank.namespaces(cluster, false) {
+			ns := cluster.namespaces[name]
+			table.rows = append(table.rows, []string{cluster.name, name, strconv.Itoa(len(ns.pods)),
+				strconv.Itoa(ns.statusSummary.running), strconv.Itoa(ns.statusSummary.pending), strconv.Itoa(ns.statusSummary.failed),
+				strconv.Itoa(len(ns.virtualServices)), strconv.Itoa(len(ns.configMaps)), strconv.Itoa(len(ns.secrets)), strconv.Itoa(len(ns.images)),
+				itoa(ns.totalCPURequest), itoa(ns.totalRAMRequest / 1024 / 1024)})
+		}
+	}
+	return table
+}
+
+func deploymentTable(clusters []clusterDetail, deployments map[string]deployInfo, rank ranking) reportTable {
+	table := reportTable{name: "deployments", title: "Deployment Breakdown", columns: []string{"Pods", "Workload", "CPU (m)", "RAM (MB)"}}
+	for _, name := range rank.workloads(clusters, deployments) {
+		info := deployments[name]
+		table.rows = append(table.rows, []string{strconv.Itoa(info.count), name, itoa(info.totalCPURequest), itoa(info.totalRAMRequest / 1024 / 1024)})
+	}
+	return table
+}
+
+func imageTable(images map[string]imageInfo, rank ranking) reportTable {
+	table := reportTable{name: "images", title: "Image Breakdown", columns: []string{"Containers", "Registry", "Repository", "Version", "Vulnerabilities", "Other running digests"}}
+	for _, key := range rank.images(images) {
+		info := images[key]
+		vulns := ""
+		if info.scanned {
+			vulns = vulnSummary(info.vulns)
+		}
+		var running []string
+		for _, digest := range info.runningDigests {
+			if digest != info.imageDigest {
+				running = append(running, digest)
+			}
+		}
+		table.rows = append(table.rows, []string{strconv.Itoa(info.count), info.imageRepo, info.imageName, info.imageVersion, vulns, strings.Join(running, " ")})
+	}
+	return table
+}
+
+func instanceTypeTable(clusters []clusterDetail, rank ranking) reportTable {
+	table := reportTable{name: "instance-types", title: "Instance Type Breakdown",
+		columns: []string{"Cluster", "Count", "Instance type", "vCPU", "RAM (GiB)", "Local storage (GiB)"}}
+	for _, cluster := range clusters {
+		_, types := summarizeNodes(cluster)
+		for _, info := range rank.instanceTypeSummaries(types) {
+			table.rows = append(table.rows, []string{cluster.name, strconv.Itoa(info.Count), info.Name, itoa(info.VCPU), itoa(info.MemoryGiB), itoa(info.Storage)})
+		}
+	}
+	return table
+}
+
+func nodeTable(clusters []clusterDetail, rank ranking) reportTable {
+	table := reportTable{name: "nodes", title: "Node Breakdown",
+		columns: []string{"Cluster", "Node", "Group", "Age", "Capacity type", "Zone", "CPUs", "RAM (GiB)", "Instance type", "IP", "Pods", "Requested vCPU", "Requested GiB", "Labels", "Volumes", "Warnings", "Taints"}}
+	for _, cluster := range clusters {
+		nodes, _ := summarizeNodes(cluster)
+		for _, n := range rank.nodeSummaries(cluster, nodes) {
+			table.rows = append(table.rows, []string{cluster.name, n.Name, n.Group, n.Age, n.CapacityType, n.Zone, n.CPUs, itoa(n.MemoryGiB),
+				n.InstanceType, n.IP, strconv.Itoa(n.Pods), itoa(n.RequestCPU), itoa(n.RequestGiB),
+				strconv.Itoa(n.Labels), strconv.Itoa(n.Volumes), n.Warnings, strings.Join(n.Taints, "; ")})
+		}
+	}
+	return table
+}
+
+func costTable(costs []costReport) reportTable {
+	table := reportTable{name: "costs", title: "Cost Showback",
+		columns: []string{"Cluster", "Rollup", "Name", "Pods", "CPU (m)", "RAM (MB)", "$/month"}}
+	for _, row := range costRows(costs) {
+		monthly := strconv.FormatFloat(row.Monthly, 'f', 2, 64)
+		if row.Rollup == "idle" {
+			table.rows = append(table.rows, []string{row.Cluster, row.Rollup, "", "", "", "", monthly})
+			continue
+		}
+		table.rows = append(table.rows, []string{row.Cluster, row.Rollup, row.Name, strconv.Itoa(row.Pods),
+			itoa(row.CPUMilli), itoa(row.MemoryBytes / 1024 / 1024), monthly})
+	}
+	return table
+}
+
+// reportTables are the tables every non-terminal format exports, in report order, ranked the
+// same way as the terminal breakdowns, which draw from the same tables. The cost table is only
+// there with -pricing.
+func reportTables(clusters []clusterDetail, deployments map[string]deployInfo, images map[string]imageInfo, costs []costReport, rank ranking) []reportTable {
+	tables := []reportTable{
+		namespaceTable(clusters, rank),
+		deploymentTable(clusters, deployments, rank),
+		imageTable(images, rank),
+		instanceTypeTable(clusters, rank),
+		nodeTable(clusters, rank),
+	}
+	if len(costs) > 0 {
+		tables = append(tables, costTable(costs))
+	}
+	return tables
+}
+
+// textRenderer draws report tables for the terminal under the usual colored headings. Every
+// column is as wide as its widest cell and columns of numbers are right-aligned.
+type textRenderer struct {
+	w io.Writer
+}
+
+func (r textRenderer) render(tables []reportTable) error {
+	var goodColor = colorString(32, false)
+	var normalColor = colorString(37, false)
+	var darkGray = colorString(30, false)
+
+	for _, table := range tables {
+		fmt.Fprintf(r.w, "\n%s===== %s%s%s =====%s\n", darkGray, goodColor, table.title, darkGray, normalColor)
+		var widths = make([]int, len(table.columns))
+		var numeric = make([]bool, len(table.columns))
+		for i, column := range table.columns {
+			widths[i] = utf8.RuneCountInString(column)
+			numeric[i] = len(table.rows) > 0
+		}
+		for _, row := range table.rows {
+			for i, cell := range row {
+				if n := utf8.RuneCountInString(cell); n > widths[i] {
+					widths[i] = n
+				}
+				if _, err := strconv.ParseInt(cell, 10, 64); err != nil {
+					numeric[i] = false
+				}
+			}
+		}
+		line := func(cells []string) string {
+			var padded []string
+			for i, cell := range cells {
+				pad := strings.Repeat(" ", widths[i]-utf8.RuneCountInString(cell))
+				if numeric[i] {
+					padded = append(padded, pad+cell)
+				} else {
+					padded = append(padded, cell+pad)
+				}
+			}
+			return strings.TrimRight(" "+strings.Join(padded, "  "), " ")
+		}
+		fmt.Fprintf(r.w, "%s%s%s\n", darkGray, line(table.columns), normalColor)
+		for _, row := range table.rows {
+			if _, err := fmt.Fprintln(r.w, line(row)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// markdownRenderer writes GitHub flavored Markdown tables under a heading each.
+type markdownRenderer struct {
+	w io.Writer
+}
+
+func markdownCell(cell string) string {
+	return strings.Replace(strings.Replace(cell, "|", "\\|", -1), "\n", " ", -1)
+}
+
+func (r markdownRenderer) render(tables []reportTable) error {
+	for i, table := range tables {
+		if i > 0 {
+			fmt.Fprintln(r.w)
+		}
+		fmt.Fprintf(r.w, "## %s\n\n", table.title)
+		fmt.Fprintf(r.w, "| %s |\n", strings.Join(table.columns, " | "))
+		var rule []string
+		for range table.columns {
+			rule = append(rule, "---")
+		}
+		fmt.Fprintf(r.w, "| %s |\n", strings.Join(rule, " | "))
+		for _, row := range table.rows {
+			var cells []string
+			for _, cell := range row {
+				cells = append(cells, markdownCell(cell))
+			}
+			if _, err := fmt.Fprintf(r.w, "| %s |\n", strings.Join(cells, " | ")); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// csvRenderer writes one CSV file per table into dir, or every table to w one after the other,
+// separated by a blank line, when dir is empty.
+type csvRenderer struct {
+	w   io.Writer
+	dir string
+}
+
+func writeCSVTable(w io.Writer, table reportTable) error {
+	writer := csv.NewWriter(w)
+	writer.Write(table.columns)
+	writer.WriteAll(table.rows)
+	return writer.Error()
+}
+
+func (r csvRenderer) render(tables []reportTable) error {
+	if r.dir == "" {
+		for i, table := range tables {
+			if i > 0 {
+				fmt.Fprintln(r.w)
+			}
+			if err := writeCSVTable(r.w, table); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err := os.MkdirAll(r.dir, 0755); err != nil {
+		return err
+	}
+	for _, table := range tables {
+		file, err := os.Create(filepath.Join(r.dir, table.name+".csv"))
+		if err != nil {
+			return err
+		}
+		err = writeCSVTable(file, table)
+		file.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -2,11 +2,12 @@ package main
 
 import (
 	"context"
-	"regexp"
 	"strings"
 	"time"
 
 	progressbar "github.com/schollz/progressbar/v3"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
@@ -14,52 +15,86 @@ import (
 	"k8s.io/client-go/kubernetes"
 )
 
+// virtualServiceGVR represents an Istio Virtual Service.
+var virtualServiceGVR = schema.GroupVersionResource{
+	Group:    "networking.istio.io",
+	Version:  "v1alpha3",
+	Resource: "virtualservices",
+}
+
 type vsMaps struct {
 	name string
 	vs   []unstructured.Unstructured
 }
 
-func scanClusterNamespaces(c *kubernetes.Clientset, dynamicClient dynamic.Interface, progressBar *progressbar.ProgressBar) map[string]nameSpaceDetail {
+func scanClusterNamespaces(c *kubernetes.Clientset, dynamicClient dynamic.Interface, progressBar *progressbar.ProgressBar, filter scanFilter) (map[string]nameSpaceDetail, error) {
 	var nsDetails = make(map[string]nameSpaceDetail)
-	var n string
+	// Forbidden and missing APIs are expected with narrow RBAC or without Istio and only leave
+	// gaps. Anything else means the scan is incomplete, which the first such error reports.
+	var failure error
+	failed := func(err error) {
+		if failure == nil && !errors.IsForbidden(err) && !errors.IsNotFound(err) {
+			failure = err
+		}
+	}
 	// var progressIterator float64
 	// var progressValue int64
 	//var vs = make(map[string]vsMaps)
 	//var configMaps = make(map[string][]configMapInfo)
 	//var secrets = make(map[string][]secretInfo)
-	//		 Create a GVR which represents an Istio Virtual Service.
-	virtualServiceGVR := schema.GroupVersionResource{
-		Group:    "networking.istio.io",
-		Version:  "v1alpha3",
-		Resource: "virtualservices",
-	}
-
 	//	var ingresses []v1.IngressClassList
 	//fmt.Printf("Gathering Data...\n")
 
 	// progressIterator = float64((len(namespaces.Items) + len(virtualServices.Items) + len(secretList.Items) + len(configMapList.Items) + len(pods.Items)) / 1000)
 	// progressValue = 0
 
-	namespaces, _ := c.CoreV1().Namespaces().List(context.TODO(), metav1.ListOptions{})
+	var matched []string
+	namespaces, err := c.CoreV1().Namespaces().List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		failed(err)
+		// Without access to the namespace list, literal --namespace values are still good.
+		namespaces = &v1.NamespaceList{}
+		for _, pattern := range filter.namespaces {
+			if pattern.literal() {
+				namespaces.Items = append(namespaces.Items, v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: pattern.text}})
+			}
+		}
+	}
 	for _, ns := range namespaces.Items {
+		if !filter.includesNamespace(ns.Name) {
+			continue
+		}
+		matched = append(matched, ns.Name)
 		nsDetails[ns.Name] = nameSpaceDetail{
 			name:            ns.Name,
+			labels:          ns.Labels,
+			created:         ns.CreationTimestamp.Time,
 			totalCPURequest: 0,
 			totalRAMRequest: 0,
 		}
 	}
+	scopes := filter.scopes(matched)
 	progressBar.Add(1)
 	//	fmt.Printf("%d Done.\n", len(nsDetails))
 
 	//  Gather all of the Virtual Services.
-	virtualServices, _ := dynamicClient.Resource(virtualServiceGVR).Namespace("").List(context.TODO(), metav1.ListOptions{})
-	for _, v := range virtualServices.Items {
-		nsName := v.GetNamespace()
-		// var tempvs vsMaps
-		// tempvs.name = nsName
-		thisNS := nsDetails[nsName]
-		thisNS.virtualServices = append(thisNS.virtualServices, v)
-		nsDetails[nsName] = thisNS
+	for _, scope := range scopes {
+		virtualServices, err := dynamicClient.Resource(virtualServiceGVR).Namespace(scope).List(context.TODO(), filter.listOptions(""))
+		if err != nil {
+			failed(err)
+			continue
+		}
+		for _, v := range virtualServices.Items {
+			nsName := v.GetNamespace()
+			if !filter.includesNamespace(nsName) {
+				continue
+			}
+			// var tempvs vsMaps
+			// tempvs.name = nsName
+			thisNS := nsDetails[nsName]
+			thisNS.virtualServices = append(thisNS.virtualServices, v)
+			nsDetails[nsName] = thisNS
+		}
 	}
 	progressBar.Add(1)
 
@@ -67,183 +102,215 @@ func scanClusterNamespaces(c *kubernetes.Clientset, dynamicClient dynamic.Interf
 	// ing.Items
 
 	//Gather Secrets:
-	secretList, _ := c.CoreV1().Secrets("").List(context.TODO(), metav1.ListOptions{})
-
-	for _, s := range secretList.Items {
-		nsName := s.GetNamespace()
-		var tempsecret secretInfo
-		tempsecret.name = s.Name
-		tempsecret.data = s
-		thisNS := nsDetails[nsName]
-		thisNS.secrets = append(thisNS.secrets, tempsecret)
-		nsDetails[nsName] = thisNS
+	for _, scope := range scopes {
+		secretList, err := c.CoreV1().Secrets(scope).List(context.TODO(), filter.listOptions(""))
+		if err != nil {
+			failed(err)
+			continue
+		}
+		for _, s := range secretList.Items {
+			nsName := s.GetNamespace()
+			if !filter.includesNamespace(nsName) {
+				continue
+			}
+			var tempsecret secretInfo
+			tempsecret.name = s.Name
+			tempsecret.data = s
+			thisNS := nsDetails[nsName]
+			thisNS.secrets = append(thisNS.secrets, tempsecret)
+			nsDetails[nsName] = thisNS
+		}
 	}
 	progressBar.Add(1)
 
 	// Gather ConfigMaps
-	configMapList, _ := c.CoreV1().ConfigMaps("").List(context.TODO(), metav1.ListOptions{})
+	for _, scope := range scopes {
+		configMapList, err := c.CoreV1().ConfigMaps(scope).List(context.TODO(), filter.listOptions(""))
+		if err != nil {
+			failed(err)
+			continue
+		}
+		for _, cm := range configMapList.Items {
+			nsName := cm.GetNamespace()
+			if !filter.includesNamespace(nsName) {
+				continue
+			}
+			var tempConfigMap configMapInfo
+			tempConfigMap.name = cm.Name
+			tempConfigMap.data = cm
+			thisNS := nsDetails[nsName]
+			thisNS.configMaps = append(thisNS.configMaps, tempConfigMap)
+			nsDetails[nsName] = thisNS
+		}
+	}
+	progressBar.Add(1)
 
-	for _, cm := range configMapList.Items {
-		nsName := cm.GetNamespace()
-		var tempConfigMap configMapInfo
-		tempConfigMap.name = cm.Name
-		tempConfigMap.data = cm
-		thisNS := nsDetails[nsName]
-		thisNS.configMaps = append(thisNS.configMaps, tempConfigMap)
-		nsDetails[n] = thisNS
+	// Gather Deployment replica counts
+	for _, scope := range scopes {
+		deployments, err := c.AppsV1().Deployments(scope).List(context.TODO(), filter.listOptions(""))
+		if err != nil {
+			failed(err)
+			continue
+		}
+		for _, d := range deployments.Items {
+			if !filter.includesNamespace(d.Namespace) {
+				continue
+			}
+			desired := int32(1)
+			if d.Spec.Replicas != nil {
+				desired = *d.Spec.Replicas
+			}
+			thisNS := nsDetails[d.Namespace]
+			thisNS.name = d.Namespace
+			thisNS.replicas = append(thisNS.replicas, deploymentReplicas{name: d.Name, desired: desired,
+				ready: d.Status.ReadyReplicas, available: d.Status.AvailableReplicas})
+			nsDetails[d.Namespace] = thisNS
+		}
 	}
 	progressBar.Add(1)
 
+	// Gather the latest scheduling failures, so pending pods can say why.
+	var failedScheduling []v1.Event
+	for _, scope := range scopes {
+		if events, err := c.CoreV1().Events(scope).List(context.TODO(), filter.listOptions("reason=FailedScheduling")); err == nil {
+			failedScheduling = append(failedScheduling, events.Items...)
+		} else {
+			failed(err)
+		}
+	}
+	schedulingEvents := latestFailedScheduling(failedScheduling)
+	progressBar.Add(1)
+
 	//fmt.Printf("Scanning Pods...")
 	//var deployments = make(map[string]deployInfo)
-	pods, _ := c.CoreV1().Pods("").List(context.TODO(), metav1.ListOptions{})
+	for _, scope := range scopes {
+		pods, err := c.CoreV1().Pods(scope).List(context.TODO(), filter.podListOptions())
+		if err != nil {
+			failed(err)
+			continue
+		}
+		for i := 0; i < len(pods.Items); i++ {
+			if filter.includesNamespace(pods.Items[i].Namespace) {
+				addPod(nsDetails, pods.Items[i], schedulingEvents)
+			}
+		} // End Pod Loop
+	}
+	//fmt.Printf("Done.\n")
+	progressBar.Add(1)
+	//nsDetails[n].deployments = deployments
+	return nsDetails, failure
+}
 
-	for i := 0; i < len(pods.Items); i++ {
-		pod := pods.Items[i]
-		n = pods.Items[i].Namespace
+// addPod folds one pod into its namespace in nsDetails: status, images, owner and requests.
+func addPod(nsDetails map[string]nameSpaceDetail, pod v1.Pod, schedulingEvents map[string]v1.Event) {
+	n := pod.Namespace
 
-		var thisNS nameSpaceDetail
+	var thisNS nameSpaceDetail
 
-		if _, ok := nsDetails[n]; ok {
-			thisNS = nsDetails[n]
-		}
+	if _, ok := nsDetails[n]; ok {
+		thisNS = nsDetails[n]
+	}
 
+	if thisNS.images == nil {
 		thisNS.images = make(map[string]imageInfo)
+	}
+	if thisNS.deployments == nil {
 		thisNS.deployments = make(map[string]deployInfo)
-		thisNS.name = n
-
-		// deploy, _ := c.AppsV1().Deployments(n).List(context.TODO(), metav1.ListOptions{})
-		// for i := 0; i < len(deploy.Items); i++ {
-
-		// }
-
-		// hpa, _ := c.AutoscalingV1().HorizontalPodAutoscalers(n).List(context.TODO(), metav1.ListOptions{})
-		// for i := 0; i < len(hpa.Items); i++ {
-		// 	hpa.Items[i].Name()
-		// }
-
-		// Start with empty pod info and then we fill it.
-		var podDetails podInfo
-		memoryRequests := int64(0)
-		cpuRequests := int64(0)
-
-		switch pod.Status.Phase {
-		case "Running":
-			thisNS.statusSummary.running++
-		case "Pending":
-			thisNS.statusSummary.pending++
-		case "Failed":
-			thisNS.statusSummary.failed++
-		case "Completed":
-			thisNS.statusSummary.completed++
-		default:
-			thisNS.statusSummary.other++
-		}
-
-		// Container Loop
-		for c := 0; c < len(pod.Spec.Containers); c++ {
-			memoryRequests += int64(pod.Spec.Containers[c].Resources.Requests.Memory().Value())
-			cpuRequests += int64(pod.Spec.Containers[c].Resources.Requests.Cpu().MilliValue())
-			image := pod.Spec.Containers[c].Image
-			// Image in this format: 590528590067.dkr.ecr.us-west-2.amazonaws.com/forrent-etl-java-cronjobs:9a4f4546e04dd3d314d639d7b2e7cc2e15ee2cac or :1.23.59a
-			r, _ := regexp.Compile("^([0-9a-zA-Z.-]+)/([-0-9a-zA-Z./]+):([-0-9a-zA-Z.]+)$")
-
-			var matches [3]string
-			for index, match := range r.FindStringSubmatch(image) {
-				if index > 0 {
-					matches[index-1] = match
-				}
-			}
+	}
+	thisNS.name = n
 
-			if _, ok := thisNS.images[image]; ok {
-				// increment
-				thisNS.images[image] = imageInfo{
-					count:        thisNS.images[image].count + 1,
-					imageKey:     image,
-					imageName:    matches[1],
-					imageRepo:    matches[0],
-					imageVersion: matches[2],
-				}
-			} else {
-				thisNS.images[image] = imageInfo{
-					imageName:    matches[1],
-					imageKey:     image,
-					imageVersion: matches[2],
-					imageRepo:    matches[0],
-					count:        1,
-				}
-			}
+	// deploy, _ := c.AppsV1().Deployments(n).List(context.TODO(), metav1.ListOptions{})
+	// for i := 0; i < len(deploy.Items); i++ {
 
-		} // End Container Loop
-
-		//		ownerName, ownerKind := findOwner(c, n, strings.TrimSpace(pods.Items[i].OwnerReferences[0].Name), strings.TrimSpace(pods.Items[i].OwnerReferences[0].Kind))
-		ownerName, ownerKind := findPseudoOwner(strings.TrimSpace(pods.Items[i].OwnerReferences[0].Name), strings.TrimSpace(pods.Items[i].OwnerReferences[0].Kind))
-		//fmt.Println(" - ", pods.Items[i].Name, pods.Items[i].OwnerReferences[0].Name, ownerName, cpuRequests, thisNS.deployments[ownerName].totalCPURequest, memoryRequests)
-		if _, ok := thisNS.deployments[ownerName]; ok {
-			// increment
-			thisNS.deployments[ownerName] = deployInfo{
-				count:           thisNS.deployments[ownerName].count + 1,
-				totalCPURequest: thisNS.deployments[ownerName].totalCPURequest + cpuRequests,
-				totalRAMRequest: thisNS.deployments[ownerName].totalRAMRequest + memoryRequests,
-			}
-		} else {
-			thisNS.deployments[ownerName] = deployInfo{
-				name:            ownerName,
-				count:           1,
-				kind:            ownerKind,
-				totalCPURequest: cpuRequests,
-				totalRAMRequest: memoryRequests,
-			}
+	// }
+
+	// hpa, _ := c.AutoscalingV1().HorizontalPodAutoscalers(n).List(context.TODO(), metav1.ListOptions{})
+	// for i := 0; i < len(hpa.Items); i++ {
+	// 	hpa.Items[i].Name()
+	// }
+
+	// Start with empty pod info and then we fill it.
+	var podDetails podInfo
+
+	diagnosis := diagnosePod(pod)
+	thisNS.statusSummary.add(podStatus(pod))
+	if diagnosis.reason == "CrashLoopBackOff" || diagnosis.reason == "OOMKilled" {
+		thisNS.statusSummary.crashlooping++
+	}
+
+	// Container Loop
+	cpuRequests, memoryRequests, containers := collectContainers(pod, thisNS.images)
+	configMapRefs, secretRefs := podReferences(pod)
+
+	//		ownerName, ownerKind := findOwner(c, n, strings.TrimSpace(pod.OwnerReferences[0].Name), strings.TrimSpace(pod.OwnerReferences[0].Kind))
+	ownerName, ownerKind := "", ""
+	if len(pod.OwnerReferences) > 0 {
+		ownerName, ownerKind = findPseudoOwner(strings.TrimSpace(pod.OwnerReferences[0].Name), strings.TrimSpace(pod.OwnerReferences[0].Kind))
+	}
+	//fmt.Println(" - ", pod.Name, pod.OwnerReferences[0].Name, ownerName, cpuRequests, thisNS.deployments[ownerName].totalCPURequest, memoryRequests)
+	if _, ok := thisNS.deployments[ownerName]; ok {
+		// increment
+		thisNS.deployments[ownerName] = deployInfo{
+			name:            ownerName,
+			kind:            ownerKind,
+			count:           thisNS.deployments[ownerName].count + 1,
+			totalCPURequest: thisNS.deployments[ownerName].totalCPURequest + cpuRequests,
+			totalRAMRequest: thisNS.deployments[ownerName].totalRAMRequest + memoryRequests,
 		}
+	} else {
+		thisNS.deployments[ownerName] = deployInfo{
+			name:            ownerName,
+			count:           1,
+			kind:            ownerKind,
+			totalCPURequest: cpuRequests,
+			totalRAMRequest: memoryRequests,
+		}
+	}
 
-		maxRestartCount := int32(0)
-		podRunningTime := int64(0)
-		for x := 0; x < len(pod.Status.ContainerStatuses); x++ {
-			if pod.Status.ContainerStatuses[x].RestartCount > maxRestartCount {
-				maxRestartCount = pod.Status.ContainerStatuses[x].RestartCount
-			}
-			runTime := int64(time.Now().Unix() - pod.Status.StartTime.Unix())
-			if runTime > podRunningTime {
-				podRunningTime = runTime
-			}
+	maxRestartCount := int32(0)
+	podRunningTime := int64(0)
+	for x := 0; x < len(pod.Status.ContainerStatuses); x++ {
+		if pod.Status.ContainerStatuses[x].RestartCount > maxRestartCount {
+			maxRestartCount = pod.Status.ContainerStatuses[x].RestartCount
+		}
+		if pod.Status.StartTime == nil {
+			continue
+		}
+		runTime := int64(time.Now().Unix() - pod.Status.StartTime.Unix())
+		if runTime > podRunningTime {
+			podRunningTime = runTime
+		}
+	}
 
-			//			if pods.Items[i].Status.ContainerStatuses[x].RestartCount >= restartLimit && (printPodDetails || printPodDetailsWide) { //&&
-			//						time.Now().Sub(pods.Items[i].Status.StartTime.Time).Minutes() < lastRestartWarningTime {
-			// fmt.Printf("%s RESTART WARNING: %s in the %s namespace restarted a total of %d times in the last %s! %s %s %s\n",
-			// 	warningColor,
-			// 	pods.Items[i].Name,
-			// 	n,
-			// 	pods.Items[i].Status.ContainerStatuses[x].RestartCount,
-			// 	secDiff(int64(time.Now().Unix()-pods.Items[i].Status.StartTime.Unix())),
-			// 	pods.Items[i].Status.Conditions[0].Reason,
-			// 	pods.Items[i].Status.Conditions[0].Message,
-			// 	normalColor,
-			// )
-			//			}
-		}
-
-		podDetails = podInfo{
-			count:          0,
-			name:           pods.Items[i].Name,
-			reservedMemory: int64(memoryRequests),
-			reservedCPU:    int64(cpuRequests),
-			HostIP:         pods.Items[i].Status.HostIP,
-			Phase:          pods.Items[i].Status.Phase,
-			RestartCount:   maxRestartCount,
-			podRunningTime: podRunningTime,
-		}
-
-		/// XXX thisNS needs to "pods[]"... an array like the secrets and configMaps and the like to be added later...
-
-		thisNS.totalRAMRequest += int64(memoryRequests)
-		thisNS.totalCPURequest += int64(cpuRequests)
-		// And tack it on the nsDetails!
-		thisNS.pods = append(thisNS.pods, podDetails)
-		//		thisNS.deployments = deployments
-		nsDetails[n] = thisNS
-	} // End Pod Loop
-	//fmt.Printf("Done.\n")
-	progressBar.Add(1)
-	//nsDetails[n].deployments = deployments
-	return nsDetails
+	podDetails = podInfo{
+		count:          0,
+		name:           pod.Name,
+		reservedMemory: int64(memoryRequests),
+		reservedCPU:    int64(cpuRequests),
+		HostIP:         pod.Status.HostIP,
+		Phase:          pod.Status.Phase,
+		status:         podStatus(pod),
+		RestartCount:   maxRestartCount,
+		podRunningTime: podRunningTime,
+		ownerName:      ownerName,
+		ownerKind:      ownerKind,
+		nodeName:       pod.Spec.NodeName,
+		tolerations:    pod.Spec.Tolerations,
+		labels:         pod.Labels,
+		annotations:    pod.Annotations,
+		containers:     containers,
+		configMapRefs:  configMapRefs,
+		secretRefs:     secretRefs,
+		diagnosis:      diagnosis,
+		scheduling:     schedulingStatus(pod, schedulingEvents),
+	}
+
+	/// XXX thisNS needs to "pods[]"... an array like the secrets and configMaps and the like to be added later...
+
+	thisNS.totalRAMRequest += int64(memoryRequests)
+	thisNS.totalCPURequest += int64(cpuRequests)
+	// And tack it on the nsDetails!
+	thisNS.pods = append(thisNS.pods, podDetails)
+	//		thisNS.deployments = deployments
+	nsDetails[n] = thisNS
 }
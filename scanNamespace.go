@@ -7,7 +7,6 @@ import (
 	"time"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 )
@@ -15,6 +14,7 @@ import (
 func scanNamespace(c *kubernetes.Clientset, dynamicClient dynamic.Interface, n string) nameSpaceDetail {
 	var nsDetails nameSpaceDetail
 	nsDetails.images = make(map[string]imageInfo)
+	nsDetails.deployments = make(map[string]deployInfo)
 	nsDetails.name = n
 
 	// deploy, _ := c.AppsV1().Deployments(n).List(context.TODO(), metav1.ListOptions{})
@@ -32,95 +32,53 @@ func scanNamespace(c *kubernetes.Clientset, dynamicClient dynamic.Interface, n s
 	// Gotta figure out how to do the dynamicClient here.
 	//dynamicClient, err := dynamic.NewForConfig(config)
 
-	//  Create a GVR which represents an Istio Virtual Service.
-	virtualServiceGVR := schema.GroupVersionResource{
-		Group:    "networking.istio.io",
-		Version:  "v1alpha3",
-		Resource: "virtualservices",
-	}
-
 	//  List all of the Virtual Services.
 	virtualServices, _ := dynamicClient.Resource(virtualServiceGVR).Namespace(n).List(context.TODO(), metav1.ListOptions{})
 
+	failedScheduling, _ := c.CoreV1().Events(n).List(context.TODO(), metav1.ListOptions{FieldSelector: "reason=FailedScheduling"})
+	schedulingEvents := latestFailedScheduling(failedScheduling.Items)
+
 	pods, _ := c.CoreV1().Pods(n).List(context.TODO(), metav1.ListOptions{})
 
 	// Pod Loop
 	for i := 0; i < len(pods.Items); i++ {
 		// Start with empty pod info and then we fill it.
 		var podDetails podInfo
-		memoryRequests := int64(0)
-		cpuRequests := int64(0)
-		fmt.Println(pods.Items[i].OwnerReferences[0].Name)
+
+		// Container Loop
+		cpuRequests, memoryRequests, containers := collectContainers(pods.Items[i], nsDetails.images)
+		configMapRefs, secretRefs := podReferences(pods.Items[i])
+
 		//		ownerName, ownerKind := findOwner(c, n, pods.Items[i].OwnerReferences[0].Name, pods.Items[i].OwnerReferences[0].Kind)
-		ownerName, ownerKind := findPseudoOwner(pods.Items[i].OwnerReferences[0].Name, pods.Items[i].OwnerReferences[0].Kind)
+		ownerName, ownerKind := "", ""
+		if len(pods.Items[i].OwnerReferences) > 0 {
+			ownerName, ownerKind = findPseudoOwner(pods.Items[i].OwnerReferences[0].Name, pods.Items[i].OwnerReferences[0].Kind)
+		}
 
 		if _, ok := nsDetails.deployments[ownerName]; ok {
 			// increment
-			fmt.Printf("Adding %s\n", ownerName)
 			nsDetails.deployments[ownerName] = deployInfo{
+				name:            ownerName,
+				kind:            ownerKind,
 				count:           nsDetails.deployments[ownerName].count + 1,
 				totalCPURequest: nsDetails.deployments[ownerName].totalCPURequest + cpuRequests,
 				totalRAMRequest: nsDetails.deployments[ownerName].totalRAMRequest + memoryRequests,
 			}
 		} else {
-			fmt.Printf("Incing %s\n", ownerName)
-
 			nsDetails.deployments[ownerName] = deployInfo{
 				name:            ownerName,
+				kind:            ownerKind,
 				count:           1,
 				totalCPURequest: cpuRequests,
 				totalRAMRequest: memoryRequests,
 			}
 		}
-		switch pods.Items[i].Status.Phase {
-		case "Running":
-			nsDetails.statusSummary.running++
-		case "Pending":
-			nsDetails.statusSummary.pending++
-		case "Failed":
-			nsDetails.statusSummary.failed++
-		case "Completed":
-			nsDetails.statusSummary.completed++
-		default:
-			nsDetails.statusSummary.other++
+		diagnosis := diagnosePod(pods.Items[i])
+		nsDetails.statusSummary.add(podStatus(pods.Items[i]))
+		if diagnosis.reason == "CrashLoopBackOff" || diagnosis.reason == "OOMKilled" {
+			nsDetails.statusSummary.crashlooping++
 		}
 
-		// Container Loop
-		for c := 0; c < len(pods.Items[i].Spec.Containers); c++ {
-			memoryRequests += int64(pods.Items[i].Spec.Containers[c].Resources.Requests.Memory().Value())
-			cpuRequests += int64(pods.Items[i].Spec.Containers[c].Resources.Requests.Cpu().MilliValue())
-			image := pods.Items[i].Spec.Containers[c].Image
-			// Image in this format: 590528590067.dkr.ecr.us-west-2.amazonaws.com/forrent-etl-java-cronjobs:9a4f4546e04dd3d314d639d7b2e7cc2e15ee2cac or :1.23.59a
-			r, _ := regexp.Compile("^([0-9a-zA-Z.-]+)/([-0-9a-zA-Z./]+):([-0-9a-zA-Z.]+)$")
-
-			var matches [3]string
-			for index, match := range r.FindStringSubmatch(image) {
-				if index > 0 {
-					matches[index-1] = match
-				}
-			}
-
-			if _, ok := nsDetails.images[image]; ok {
-				// increment
-				nsDetails.images[image] = imageInfo{
-					count:        nsDetails.images[image].count + 1,
-					imageKey:     image,
-					imageName:    matches[1],
-					imageRepo:    matches[0],
-					imageVersion: matches[2],
-				}
-			} else {
-				nsDetails.images[image] = imageInfo{
-					imageName:    matches[1],
-					imageKey:     image,
-					imageVersion: matches[2],
-					imageRepo:    matches[0],
-					count:        1,
-				}
-			}
-
-		} // End Container Loop
-
 		// Move this to be done in the returned location
 		// podCounter[pods.Items[i].Status.HostIP] = podInfo{
 		// 	count:          podCounter[pods.Items[i].Status.HostIP].count + 1,
@@ -139,20 +97,6 @@ func scanNamespace(c *kubernetes.Clientset, dynamicClient dynamic.Interface, n s
 			if runTime > podRunningTime {
 				podRunningTime = runTime
 			}
-
-			//			if pods.Items[i].Status.ContainerStatuses[x].RestartCount >= restartLimit && (printPodDetails || printPodDetailsWide) { //&&
-			//						time.Now().Sub(pods.Items[i].Status.StartTime.Time).Minutes() < lastRestartWarningTime {
-			// fmt.Printf("%s RESTART WARNING: %s in the %s namespace restarted a total of %d times in the last %s! %s %s %s\n",
-			// 	warningColor,
-			// 	pods.Items[i].Name,
-			// 	n,
-			// 	pods.Items[i].Status.ContainerStatuses[x].RestartCount,
-			// 	secDiff(int64(time.Now().Unix()-pods.Items[i].Status.StartTime.Unix())),
-			// 	pods.Items[i].Status.Conditions[0].Reason,
-			// 	pods.Items[i].Status.Conditions[0].Message,
-			// 	normalColor,
-			// )
-			//			}
 		}
 
 		podDetails = podInfo{
@@ -162,10 +106,20 @@ func scanNamespace(c *kubernetes.Clientset, dynamicClient dynamic.Interface, n s
 			reservedCPU:    int64(cpuRequests),
 			HostIP:         pods.Items[i].Status.HostIP,
 			Phase:          pods.Items[i].Status.Phase,
+			status:         podStatus(pods.Items[i]),
 			RestartCount:   maxRestartCount,
 			podRunningTime: podRunningTime,
 			ownerName:      ownerName,
 			ownerKind:      ownerKind,
+			nodeName:       pods.Items[i].Spec.NodeName,
+			tolerations:    pods.Items[i].Spec.Tolerations,
+			labels:         pods.Items[i].Labels,
+			annotations:    pods.Items[i].Annotations,
+			containers:     containers,
+			configMapRefs:  configMapRefs,
+			secretRefs:     secretRefs,
+			diagnosis:      diagnosis,
+			scheduling:     schedulingStatus(pods.Items[i], schedulingEvents),
 		}
 		nsDetails.virtualServices = virtualServices.Items
 		nsDetails.totalRAMRequest += int64(memoryRequests)
@@ -195,6 +149,8 @@ func findPseudoOwner(oName string, oKind string) (string, string) {
 				}
 			}
 		}
+	case "DaemonSet", "StatefulSet", "Job", "Node":
+		return oName, oKind
 	}
 	// Pod from Statefulset: costar-sync-marshaller-1
 	// Pod from DaemonSet: costar-sync-marshaller-84bfd
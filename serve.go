@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	progressbar "github.com/schollz/progressbar/v3"
+)
+
+// scanCache holds the latest good scan of every cluster for the serve command, and whether the
+// last attempt to rescan each one worked.
+type scanCache struct {
+	mutex     sync.RWMutex
+	clusters  []clusterDetail
+	scanned   time.Time
+	succeeded map[string]bool
+	lastGood  map[string]time.Time
+}
+
+func (c *scanCache) get() ([]clusterDetail, time.Time) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.clusters, c.scanned
+}
+
+func (c *scanCache) set(clusters []clusterDetail) {
+	c.update(clusters, nil)
+}
+
+// update stores one round of scans. The clusters in failed were not rescanned and still hold
+// their previous snapshot.
+func (c *scanCache) update(clusters []clusterDetail, failed map[string]bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	now := time.Now()
+	if c.succeeded == nil {
+		c.succeeded = make(map[string]bool)
+		c.lastGood = make(map[string]time.Time)
+	}
+	c.clusters = clusters
+	c.scanned = now
+	for _, cluster := range clusters {
+		c.succeeded[cluster.name] = !failed[cluster.name]
+		if !failed[cluster.name] {
+			c.lastGood[cluster.name] = now
+		}
+	}
+}
+
+// health is whether the last scan of each cluster worked, and when one last did.
+func (c *scanCache) health() (map[string]bool, map[string]time.Time) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	var succeeded = make(map[string]bool)
+	var lastGood = make(map[string]time.Time)
+	for name, ok := range c.succeeded {
+		succeeded[name] = ok
+		lastGood[name] = c.lastGood[name]
+	}
+	return succeeded, lastGood
+}
+
+// rescanCluster runs the same scan main does, without the progress bar.
+func rescanCluster(cluster clusterDetail) (clusterDetail, error) {
+	namespaces, err := scanClusterNamespaces(&cluster.clientset, cluster.dynamicClient, progressbar.DefaultSilent(-1), cluster.filter)
+	if err != nil {
+		return cluster, err
+	}
+	nodes, err := cluster.clientset.CoreV1().Nodes().List(context.TODO(), cluster.filter.nodeListOptions())
+	if err != nil {
+		return cluster, err
+	}
+	cluster.namespaces = namespaces
+	cluster.nodeList = nodes.Items
+	cluster.deprecations = countDeprecations(&cluster.clientset, cluster.filter)
+	return cluster, nil
+}
+
+// rescanAll rescans every cached cluster once. A cluster whose scan fails keeps its previous
+// snapshot, so one API hiccup does not zero its metrics.
+func rescanAll(cache *scanCache, rescan func(clusterDetail) (clusterDetail, error)) {
+	clusters, _ := cache.get()
+	var scanned []clusterDetail
+	var failed = make(map[string]bool)
+	for _, cluster := range clusters {
+		fresh, err := rescan(cluster)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Rescan of %s failed, keeping the previous scan: %v\n", cluster.name, err)
+			failed[cluster.name] = true
+			fresh = cluster
+		}
+		scanned = append(scanned, fresh)
+	}
+	cache.update(scanned, failed)
+}
+
+// rescanEvery rescans the cache every interval until ctx is done.
+func rescanEvery(ctx context.Context, cache *scanCache, interval time.Duration, rescan func(clusterDetail) (clusterDetail, error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			rescanAll(cache, rescan)
+		}
+	}
+}
+
+// runServe rescans every interval and serves the results as Prometheus metrics and a JSON API
+// until SIGINT or SIGTERM, then stops rescanning and shuts the listeners down. The API shares
+// the metrics listener unless apiAddr is set.
+func runServe(clusters []clusterDetail, metricsAddr, apiAddr string, interval time.Duration, vulnDB advisoryDB) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	cache := &scanCache{}
+	for i := range clusters {
+		clusters[i].deprecations = countDeprecations(&clusters[i].clientset, clusters[i].filter)
+	}
+	cache.set(clusters)
+	go rescanEvery(ctx, cache, interval, rescanCluster)
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(scanCollector{cache: cache})
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	servers := []*http.Server{{Addr: metricsAddr, Handler: mux}}
+
+	api := scanAPI{cache: cache, vulnDB: vulnDB}
+	if apiAddr == "" || apiAddr == metricsAddr {
+		api.register(mux)
+		fmt.Printf("Serving the API on %s\n", metricsAddr)
+	} else {
+		apiMux := http.NewServeMux()
+		api.register(apiMux)
+		fmt.Printf("Serving the API on %s\n", apiAddr)
+		apiServer := &http.Server{Addr: apiAddr, Handler: apiMux}
+		servers = append(servers, apiServer)
+		go func() {
+			if err := apiServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				panic(err.Error())
+			}
+		}()
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		for _, server := range servers {
+			server.Shutdown(shutdown)
+		}
+	}()
+
+	fmt.Printf("Serving metrics on %s/metrics, rescanning every %s\n", metricsAddr, interval)
+	if err := servers[0].ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		panic(err.Error())
+	}
+	<-stopped
+	fmt.Fprintln(os.Stderr, "Stopped serving")
+}
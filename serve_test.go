@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func servedCluster(name string, running int) clusterDetail {
+	return clusterDetail{name: name, namespaces: map[string]nameSpaceDetail{
+		"web": {name: "web", statusSummary: podStatusSummary{running: running}},
+	}}
+}
+
+func TestRescanKeepsSnapshotOnFailure(t *testing.T) {
+	cache := &scanCache{}
+	cache.set([]clusterDetail{servedCluster("prod", 3), servedCluster("staging", 1)})
+	firstRound := time.Now()
+
+	rescanAll(cache, func(cluster clusterDetail) (clusterDetail, error) {
+		if cluster.name == "prod" {
+			return clusterDetail{name: "prod"}, errors.New("the server is currently unable to handle the request")
+		}
+		return servedCluster(cluster.name, 2), nil
+	})
+
+	clusters, _ := cache.get()
+	if running := clusters[0].namespaces["web"].statusSummary.running; running != 3 {
+		t.Errorf("prod has %d running pods after a failed rescan, want the previous 3", running)
+	}
+	if running := clusters[1].namespaces["web"].statusSummary.running; running != 2 {
+		t.Errorf("staging has %d running pods after its rescan, want 2", running)
+	}
+
+	expected := `
+# HELP kube_helper_last_scan_success Whether the last scan of the cluster worked; when it did not, the metrics are from the one before.
+# TYPE kube_helper_last_scan_success gauge
+kube_helper_last_scan_success{cluster="prod"} 0
+kube_helper_last_scan_success{cluster="staging"} 1
+`
+	if err := testutil.CollectAndCompare(scanCollector{cache: cache}, strings.NewReader(expected), "kube_helper_last_scan_success"); err != nil {
+		t.Error(err)
+	}
+
+	succeeded, lastGood := cache.health()
+	if succeeded["prod"] || !succeeded["staging"] {
+		t.Errorf("scan success %v, want prod failed and staging fine", succeeded)
+	}
+	if lastGood["prod"].IsZero() || lastGood["prod"].After(firstRound) || lastGood["staging"].Before(firstRound) {
+		t.Errorf("last good scans %v, want prod's from the first round", lastGood)
+	}
+}
+
+func TestRescanEveryStops(t *testing.T) {
+	cache := &scanCache{}
+	cache.set([]clusterDetail{servedCluster("prod", 1)})
+	rescans := make(chan bool, 100)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		rescanEvery(ctx, cache, time.Millisecond, func(cluster clusterDetail) (clusterDetail, error) {
+			rescans <- true
+			return cluster, nil
+		})
+		close(done)
+	}()
+
+	<-rescans
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("rescanning went on after the context was cancelled")
+	}
+}
+
+func TestDeploymentReplicaMetrics(t *testing.T) {
+	cache := &scanCache{}
+	cache.set([]clusterDetail{{name: "prod", namespaces: map[string]nameSpaceDetail{
+		"web": {name: "web", replicas: []deploymentReplicas{{name: "frontend", desired: 3, ready: 2, available: 1}}},
+	}}})
+
+	expected := `
+# HELP kube_helper_deployment_spec_replicas Replicas a Deployment asks for.
+# TYPE kube_helper_deployment_spec_replicas gauge
+kube_helper_deployment_spec_replicas{cluster="prod",deployment="frontend",namespace="web"} 3
+# HELP kube_helper_deployment_status_replicas_available Available replicas of a Deployment.
+# TYPE kube_helper_deployment_status_replicas_available gauge
+kube_helper_deployment_status_replicas_available{cluster="prod",deployment="frontend",namespace="web"} 1
+# HELP kube_helper_deployment_status_replicas_ready Ready replicas of a Deployment.
+# TYPE kube_helper_deployment_status_replicas_ready gauge
+kube_helper_deployment_status_replicas_ready{cluster="prod",deployment="frontend",namespace="web"} 2
+`
+	if err := testutil.CollectAndCompare(scanCollector{cache: cache}, strings.NewReader(expected),
+		"kube_helper_deployment_spec_replicas", "kube_helper_deployment_status_replicas_ready", "kube_helper_deployment_status_replicas_available"); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,530 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// TUI views. Clusters drill into namespaces, then workloads, then pods; a cluster's nodes drill
+// into the pods running on them.
+const (
+	tuiClusters = iota
+	tuiNamespaces
+	tuiWorkloads
+	tuiPods
+	tuiNodes
+	tuiNodePods
+)
+
+var tuiSortKeys = []string{"name", "cpu", "ram", "restarts", "age"}
+
+type tuiColumn struct {
+	title string
+	right bool
+}
+
+type tuiRow struct {
+	key      string
+	cells    []string
+	color    tcell.Color
+	cpu      int64
+	ram      int64
+	restarts int32
+	age      int64
+}
+
+type tuiView struct {
+	kind      int
+	cluster   int
+	namespace string
+	workload  string
+	node      string
+	selected  int
+	offset    int
+}
+
+// tui is the interactive browser over the scanned model. It only talks to a tcell.Screen, so
+// it can be driven by tcell's SimulationScreen as well as a real terminal.
+type tui struct {
+	screen    tcell.Screen
+	clusters  []clusterDetail
+	stack     []tuiView
+	sortBy    int
+	filter    string
+	filtering bool
+}
+
+func newTUI(screen tcell.Screen, clusters []clusterDetail) *tui {
+	t := &tui{screen: screen, clusters: clusters, stack: []tuiView{{kind: tuiClusters}}}
+	if len(clusters) == 1 {
+		t.stack = append(t.stack, tuiView{kind: tuiNamespaces})
+	}
+	return t
+}
+
+// runTUI opens the terminal and browses clusters until the user quits.
+func runTUI(clusters []clusterDetail) error {
+	screen, err := tcell.NewScreen()
+	if err != nil {
+		return err
+	}
+	if err := screen.Init(); err != nil {
+		return err
+	}
+	defer screen.Fini()
+	newTUI(screen, clusters).run()
+	return nil
+}
+
+func (t *tui) run() {
+	for {
+		t.draw()
+		switch ev := t.screen.PollEvent().(type) {
+		case *tcell.EventResize:
+			t.screen.Sync()
+		case *tcell.EventKey:
+			if !t.handleKey(ev) {
+				return
+			}
+		case nil:
+			return
+		}
+	}
+}
+
+func (t *tui) view() *tuiView {
+	return &t.stack[len(t.stack)-1]
+}
+
+func (t *tui) push(v tuiView) {
+	t.stack = append(t.stack, v)
+	t.filter = ""
+}
+
+// handleKey applies one key press and returns false when the TUI should exit.
+func (t *tui) handleKey(ev *tcell.EventKey) bool {
+	if t.filtering {
+		switch ev.Key() {
+		case tcell.KeyEnter:
+			t.filtering = false
+		case tcell.KeyEscape:
+			t.filtering = false
+			t.filter = ""
+		case tcell.KeyBackspace, tcell.KeyBackspace2:
+			if len(t.filter) > 0 {
+				t.filter = t.filter[:len(t.filter)-1]
+			}
+		case tcell.KeyRune:
+			t.filter += string(ev.Rune())
+		}
+		t.view().selected = 0
+		return true
+	}
+
+	rows := t.rows()
+	v := t.view()
+	switch ev.Key() {
+	case tcell.KeyCtrlC:
+		return false
+	case tcell.KeyUp:
+		v.selected--
+	case tcell.KeyDown:
+		v.selected++
+	case tcell.KeyPgUp:
+		v.selected -= t.pageSize()
+	case tcell.KeyPgDn:
+		v.selected += t.pageSize()
+	case tcell.KeyHome:
+		v.selected = 0
+	case tcell.KeyEnd:
+		v.selected = len(rows) - 1
+	case tcell.KeyEnter, tcell.KeyRight:
+		t.drill(rows)
+	case tcell.KeyEscape, tcell.KeyLeft, tcell.KeyBackspace, tcell.KeyBackspace2:
+		t.back()
+	case tcell.KeyRune:
+		switch ev.Rune() {
+		case 'q':
+			return false
+		case 'k':
+			v.selected--
+		case 'j':
+			v.selected++
+		case 'l':
+			t.drill(rows)
+		case 'h':
+			t.back()
+		case 's':
+			t.sortBy = (t.sortBy + 1) % len(tuiSortKeys)
+		case 'n':
+			if v.kind != tuiClusters && v.kind != tuiNodes {
+				t.push(tuiView{kind: tuiNodes, cluster: v.cluster})
+			}
+		case '/':
+			t.filtering = true
+			t.filter = ""
+		}
+	}
+	return true
+}
+
+func (t *tui) back() {
+	if t.filter != "" {
+		t.filter = ""
+		return
+	}
+	if len(t.stack) > 1 {
+		t.stack = t.stack[:len(t.stack)-1]
+	}
+}
+
+func (t *tui) drill(rows []tuiRow) {
+	v := t.view()
+	if v.selected < 0 || v.selected >= len(rows) {
+		return
+	}
+	key := rows[v.selected].key
+	switch v.kind {
+	case tuiClusters:
+		cluster, _ := strconv.Atoi(key)
+		t.push(tuiView{kind: tuiNamespaces, cluster: cluster})
+	case tuiNamespaces:
+		t.push(tuiView{kind: tuiWorkloads, cluster: v.cluster, namespace: key})
+	case tuiWorkloads:
+		t.push(tuiView{kind: tuiPods, cluster: v.cluster, namespace: v.namespace, workload: key})
+	case tuiNodes:
+		t.push(tuiView{kind: tuiNodePods, cluster: v.cluster, node: key})
+	}
+}
+
+func (t *tui) pageSize() int {
+	_, height := t.screen.Size()
+	if height < 4 {
+		return 1
+	}
+	return height - 3
+}
+
+// breadcrumb names where the current view is, e.g. "prod › payments › checkout".
+func (t *tui) breadcrumb() string {
+	v := t.view()
+	parts := []string{"kube-helper"}
+	if v.kind != tuiClusters {
+		parts = append(parts, t.clusters[v.cluster].name)
+	}
+	switch v.kind {
+	case tuiWorkloads:
+		parts = append(parts, v.namespace)
+	case tuiPods:
+		parts = append(parts, v.namespace, v.workload)
+	case tuiNodes:
+		parts = append(parts, "nodes")
+	case tuiNodePods:
+		parts = append(parts, "nodes", v.node)
+	}
+	return strings.Join(parts, " › ")
+}
+
+func (t *tui) columns() []tuiColumn {
+	switch t.view().kind {
+	case tuiClusters:
+		return []tuiColumn{{"CLUSTER", false}, {"NAMESPACES", true}, {"PODS", true}, {"NODES", true}, {"CPU", true}, {"RAM", true}, {"RESTARTS", true}}
+	case tuiNamespaces:
+		return []tuiColumn{{"NAMESPACE", false}, {"PODS", true}, {"RUNNING", true}, {"PENDING", true}, {"FAILED", true}, {"CPU", true}, {"RAM", true}, {"RESTARTS", true}}
+	case tuiWorkloads:
+		return []tuiColumn{{"WORKLOAD", false}, {"KIND", false}, {"PODS", true}, {"CPU", true}, {"RAM", true}, {"RESTARTS", true}, {"AGE", true}}
+	case tuiNodes:
+		return []tuiColumn{{"NODE", false}, {"GROUP", false}, {"TYPE", false}, {"PODS", true}, {"CPU", true}, {"RAM", true}, {"RESTARTS", true}, {"AGE", true}}
+	case tuiNodePods:
+		return []tuiColumn{{"POD", false}, {"NAMESPACE", false}, {"STATUS", false}, {"CPU", true}, {"RAM", true}, {"RESTARTS", true}, {"AGE", true}}
+	}
+	return []tuiColumn{{"POD", false}, {"STATUS", false}, {"NODE", false}, {"CPU", true}, {"RAM", true}, {"RESTARTS", true}, {"AGE", true}}
+}
+
+func tuiCPU(milli int64) string {
+	return fmt.Sprintf("%dm", milli)
+}
+
+func tuiRAM(bytes int64) string {
+	return fmt.Sprintf("%dMi", bytes/1024/1024)
+}
+
+func tuiStatusColor(status string) tcell.Color {
+	switch status {
+	case "Running", "Completed":
+		return tcell.ColorGreen
+	case "Pending", "Failed", "Unknown":
+		return tcell.ColorRed
+	case "NotReady", "Terminating", "Other":
+		return tcell.ColorYellow
+	}
+	return tcell.ColorWhite
+}
+
+func podRow(key string, pod podInfo, cells ...string) tuiRow {
+	return tuiRow{
+		key:      key,
+		cells:    cells,
+		color:    tuiStatusColor(pod.status),
+		cpu:      pod.reservedCPU,
+		ram:      pod.reservedMemory,
+		restarts: pod.RestartCount,
+		age:      pod.podRunningTime,
+	}
+}
+
+// allRows builds the unfiltered rows of the current view from the scan model.
+func (t *tui) allRows() []tuiRow {
+	var rows []tuiRow
+	v := t.view()
+	switch v.kind {
+	case tuiClusters:
+		for i, cluster := range t.clusters {
+			row := tuiRow{key: strconv.Itoa(i), color: tcell.ColorWhite}
+			pods := 0
+			for _, ns := range cluster.namespaces {
+				pods += len(ns.pods)
+				row.cpu += ns.totalCPURequest
+				row.ram += ns.totalRAMRequest
+				for _, pod := range ns.pods {
+					row.restarts += pod.RestartCount
+				}
+			}
+			row.cells = []string{cluster.name, strconv.Itoa(len(cluster.namespaces)), strconv.Itoa(pods), strconv.Itoa(len(cluster.nodeList)),
+				tuiCPU(row.cpu), tuiRAM(row.ram), strconv.Itoa(int(row.restarts))}
+			rows = append(rows, row)
+		}
+
+	case tuiNamespaces:
+		for _, ns := range t.clusters[v.cluster].namespaces {
+			row := tuiRow{key: ns.name, color: tcell.ColorWhite, cpu: ns.totalCPURequest, ram: ns.totalRAMRequest}
+			if len(ns.pods) == 0 {
+				row.color = tcell.ColorGray
+			} else if ns.statusSummary.pending+ns.statusSummary.failed > 0 {
+				row.color = tcell.ColorRed
+			}
+			for _, pod := range ns.pods {
+				row.restarts += pod.RestartCount
+				if pod.podRunningTime > row.age {
+					row.age = pod.podRunningTime
+				}
+			}
+			row.cells = []string{ns.name, strconv.Itoa(len(ns.pods)), strconv.Itoa(ns.statusSummary.running), strconv.Itoa(ns.statusSummary.pending),
+				strconv.Itoa(ns.statusSummary.failed), tuiCPU(row.cpu), tuiRAM(row.ram), strconv.Itoa(int(row.restarts))}
+			rows = append(rows, row)
+		}
+
+	case tuiWorkloads:
+		var workloads = make(map[string]*tuiRow)
+		var counts = make(map[string]int)
+		for _, pod := range t.clusters[v.cluster].namespaces[v.namespace].pods {
+			name := workloadOf(pod)
+			row, ok := workloads[name]
+			if !ok {
+				kind := pod.ownerKind
+				if kind == "" {
+					kind = "Pod"
+				}
+				row = &tuiRow{key: name, color: tcell.ColorWhite, cells: []string{name, kind}}
+				workloads[name] = row
+			}
+			counts[name]++
+			row.cpu += pod.reservedCPU
+			row.ram += pod.reservedMemory
+			row.restarts += pod.RestartCount
+			if pod.podRunningTime > row.age {
+				row.age = pod.podRunningTime
+			}
+			if tuiStatusColor(pod.status) == tcell.ColorRed {
+				row.color = tcell.ColorRed
+			}
+		}
+		for name, row := range workloads {
+			row.cells = append(row.cells, strconv.Itoa(counts[name]), tuiCPU(row.cpu), tuiRAM(row.ram),
+				strconv.Itoa(int(row.restarts)), secDiff(row.age))
+			rows = append(rows, *row)
+		}
+
+	case tuiPods:
+		for _, pod := range t.clusters[v.cluster].namespaces[v.namespace].pods {
+			if workloadOf(pod) != v.workload {
+				continue
+			}
+			rows = append(rows, podRow(pod.name, pod, pod.name, pod.status, pod.nodeName, tuiCPU(pod.reservedCPU),
+				tuiRAM(pod.reservedMemory), strconv.Itoa(int(pod.RestartCount)), secDiff(pod.podRunningTime)))
+		}
+
+	case tuiNodes:
+		var onNode = make(map[string]*tuiRow)
+		var pods = make(map[string]int)
+		for _, ns := range t.clusters[v.cluster].namespaces {
+			for _, pod := range ns.pods {
+				if pod.nodeName == "" || pod.status == "Completed" || pod.status == "Failed" {
+					continue
+				}
+				if _, ok := onNode[pod.nodeName]; !ok {
+					onNode[pod.nodeName] = &tuiRow{}
+				}
+				onNode[pod.nodeName].cpu += pod.reservedCPU
+				onNode[pod.nodeName].ram += pod.reservedMemory
+				onNode[pod.nodeName].restarts += pod.RestartCount
+				pods[pod.nodeName]++
+			}
+		}
+		for _, node := range t.clusters[v.cluster].nodeList {
+			row := tuiRow{key: node.Name, color: tcell.ColorWhite}
+			if usage, ok := onNode[node.Name]; ok {
+				row.cpu, row.ram, row.restarts = usage.cpu, usage.ram, usage.restarts
+			}
+			row.age = int64(time.Since(node.CreationTimestamp.Time).Seconds())
+			cpuPercent := 100 * float64(row.cpu) / float64(node.Status.Allocatable.Cpu().MilliValue()+1)
+			ramPercent := 100 * float64(row.ram) / float64(node.Status.Allocatable.Memory().Value()+1)
+			if cpuPercent > 90 || ramPercent > 90 {
+				row.color = tcell.ColorYellow
+			}
+			row.cells = []string{node.Name, nodeGroupOf(node), node.GetLabels()["beta.kubernetes.io/instance-type"], strconv.Itoa(pods[node.Name]),
+				fmt.Sprintf("%s %3.0f%%", tuiCPU(row.cpu), cpuPercent), fmt.Sprintf("%s %3.0f%%", tuiRAM(row.ram), ramPercent),
+				strconv.Itoa(int(row.restarts)), secDiff(row.age)}
+			rows = append(rows, row)
+		}
+
+	case tuiNodePods:
+		for _, ns := range t.clusters[v.cluster].namespaces {
+			for _, pod := range ns.pods {
+				if pod.nodeName != v.node {
+					continue
+				}
+				rows = append(rows, podRow(ns.name+"/"+pod.name, pod, pod.name, ns.name, pod.status, tuiCPU(pod.reservedCPU),
+					tuiRAM(pod.reservedMemory), strconv.Itoa(int(pod.RestartCount)), secDiff(pod.podRunningTime)))
+			}
+		}
+	}
+	return rows
+}
+
+// rows is the current view filtered by the filter text (any cell, case-insensitive) and sorted
+// by the sort key, largest first, with the name as tie-breaker.
+func (t *tui) rows() []tuiRow {
+	var rows []tuiRow
+	filter := strings.ToLower(t.filter)
+	for _, row := range t.allRows() {
+		if filter == "" || strings.Contains(strings.ToLower(strings.Join(row.cells, " ")), filter) {
+			rows = append(rows, row)
+		}
+	}
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		a, b := rows[i], rows[j]
+		var x, y int64
+		switch tuiSortKeys[t.sortBy] {
+		case "cpu":
+			x, y = a.cpu, b.cpu
+		case "ram":
+			x, y = a.ram, b.ram
+		case "restarts":
+			x, y = int64(a.restarts), int64(b.restarts)
+		case "age":
+			x, y = a.age, b.age
+		}
+		if x != y {
+			return x > y
+		}
+		return a.key < b.key
+	})
+	return rows
+}
+
+func (t *tui) text(x, y, width int, style tcell.Style, text string) {
+	for _, r := range text {
+		if width <= 0 {
+			return
+		}
+		t.screen.SetContent(x, y, r, nil, style)
+		x++
+		width--
+	}
+}
+
+func (t *tui) draw() {
+	t.screen.Clear()
+	width, height := t.screen.Size()
+	rows := t.rows()
+	columns := t.columns()
+	v := t.view()
+
+	if v.selected >= len(rows) {
+		v.selected = len(rows) - 1
+	}
+	if v.selected < 0 {
+		v.selected = 0
+	}
+	page := t.pageSize()
+	if v.selected < v.offset {
+		v.offset = v.selected
+	}
+	if v.selected >= v.offset+page {
+		v.offset = v.selected - page + 1
+	}
+
+	header := tcell.StyleDefault.Foreground(tcell.ColorGreen).Bold(true)
+	status := fmt.Sprintf("sort: %s  %d rows", tuiSortKeys[t.sortBy], len(rows))
+	t.text(0, 0, width, header, t.breadcrumb())
+	t.text(width-len(status), 0, len(status), tcell.StyleDefault.Foreground(tcell.ColorGray), status)
+
+	widths := make([]int, len(columns))
+	for i, column := range columns {
+		widths[i] = len(column.title)
+	}
+	for _, row := range rows {
+		for i, cell := range row.cells {
+			if i < len(widths) && len(cell) > widths[i] {
+				widths[i] = len(cell)
+			}
+		}
+	}
+	line := func(cells []string) string {
+		var parts []string
+		for i, column := range columns {
+			cell := ""
+			if i < len(cells) {
+				cell = cells[i]
+			}
+			if column.right {
+				parts = append(parts, fmt.Sprintf("%*s", widths[i], cell))
+			} else {
+				parts = append(parts, fmt.Sprintf("%-*s", widths[i], cell))
+			}
+		}
+		return strings.Join(parts, "  ")
+	}
+
+	var titles []string
+	for _, column := range columns {
+		titles = append(titles, column.title)
+	}
+	t.text(0, 1, width, tcell.StyleDefault.Bold(true), line(titles))
+	for i := v.offset; i < len(rows) && i-v.offset < page; i++ {
+		style := tcell.StyleDefault.Foreground(rows[i].color)
+		if i == v.selected {
+			style = style.Reverse(true)
+		}
+		t.text(0, 2+i-v.offset, width, style, line(rows[i].cells))
+	}
+
+	footer := "enter: open  esc: back  n: nodes  s: sort  /: filter  q: quit"
+	if t.filtering || t.filter != "" {
+		footer = "/" + t.filter
+	}
+	t.text(0, height-1, width, tcell.StyleDefault.Foreground(tcell.ColorGray), footer)
+	if t.filtering {
+		t.screen.ShowCursor(len(footer), height-1)
+	} else {
+		t.screen.HideCursor()
+	}
+	t.screen.Show()
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+const gib = 1024 * 1024 * 1024
+
+// tuiTestClusters are two clusters; prod has four namespaces that each sort first by a
+// different key.
+func tuiTestClusters() []clusterDetail {
+	namespace := func(name string, cpu, ram int64, pods ...podInfo) nameSpaceDetail {
+		return nameSpaceDetail{name: name, pods: pods, totalCPURequest: cpu, totalRAMRequest: ram}
+	}
+	pod := func(name, owner string, restarts int32, age int64) podInfo {
+		return podInfo{name: name, ownerName: owner, ownerKind: "ReplicaSet", status: "Running", RestartCount: restarts, podRunningTime: age, nodeName: "node-1"}
+	}
+	prod := clusterDetail{name: "prod", namespaces: map[string]nameSpaceDetail{
+		"alpha": namespace("alpha", 100, 4*gib, pod("web-1", "web", 0, 10)),
+		"beta":  namespace("beta", 900, 1*gib, pod("api-1", "api", 1, 20), pod("api-2", "api", 0, 5), pod("worker-1", "worker", 0, 5)),
+		"gamma": namespace("gamma", 200, 2*gib, pod("cron-1", "cron", 7, 30)),
+		"delta": namespace("delta", 300, 3*gib, pod("db-1", "db", 2, 400)),
+	}}
+	staging := clusterDetail{name: "staging", namespaces: map[string]nameSpaceDetail{
+		"alpha": namespace("alpha", 100, gib, pod("web-1", "web", 0, 10)),
+	}}
+	return []clusterDetail{prod, staging}
+}
+
+func newTestTUI(t *testing.T) (*tui, tcell.SimulationScreen) {
+	screen := tcell.NewSimulationScreen("UTF-8")
+	if err := screen.Init(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(screen.Fini)
+	screen.SetSize(120, 20)
+	ui := newTUI(screen, tuiTestClusters())
+	ui.draw()
+	return ui, screen
+}
+
+// press feeds keys to the TUI the way run does and redraws after each one.
+func press(t *testing.T, ui *tui, keys ...*tcell.EventKey) {
+	t.Helper()
+	for _, key := range keys {
+		if !ui.handleKey(key) {
+			t.Fatalf("key %s quit the TUI", key.Name())
+		}
+		ui.draw()
+	}
+}
+
+func key(k tcell.Key) *tcell.EventKey {
+	return tcell.NewEventKey(k, 0, tcell.ModNone)
+}
+
+func runes(text string) []*tcell.EventKey {
+	var keys []*tcell.EventKey
+	for _, r := range text {
+		keys = append(keys, tcell.NewEventKey(tcell.KeyRune, r, tcell.ModNone))
+	}
+	return keys
+}
+
+// screenLine is one row of the simulated terminal without trailing blanks.
+func screenLine(screen tcell.SimulationScreen, y int) string {
+	cells, width, _ := screen.GetContents()
+	var line strings.Builder
+	for _, cell := range cells[y*width : (y+1)*width] {
+		if len(cell.Runes) == 0 {
+			line.WriteRune(' ')
+		} else {
+			line.WriteRune(cell.Runes[0])
+		}
+	}
+	return strings.TrimRight(line.String(), " ")
+}
+
+// shownRows are the first cells of the table rows on screen.
+func shownRows(screen tcell.SimulationScreen) []string {
+	_, _, height := screen.GetContents()
+	var rows []string
+	for y := 2; y < height-1; y++ {
+		if fields := strings.Fields(screenLine(screen, y)); len(fields) > 0 {
+			rows = append(rows, fields[0])
+		}
+	}
+	return rows
+}
+
+func breadcrumbOf(screen tcell.SimulationScreen) string {
+	return strings.SplitN(screenLine(screen, 0), "  ", 2)[0]
+}
+
+func TestTUIDrillDownAndBack(t *testing.T) {
+	ui, screen := newTestTUI(t)
+
+	steps := []struct {
+		keys       []*tcell.EventKey
+		breadcrumb string
+		rows       []string
+	}{
+		{nil, "kube-helper", []string{"prod", "staging"}},
+		{[]*tcell.EventKey{key(tcell.KeyEnter)}, "kube-helper › prod", []string{"alpha", "beta", "delta", "gamma"}},
+		{[]*tcell.EventKey{key(tcell.KeyDown), key(tcell.KeyEnter)}, "kube-helper › prod › beta", []string{"api", "worker"}},
+		{[]*tcell.EventKey{key(tcell.KeyEnter)}, "kube-helper › prod › beta › api", []string{"api-1", "api-2"}},
+		{[]*tcell.EventKey{key(tcell.KeyEscape)}, "kube-helper › prod › beta", []string{"api", "worker"}},
+		{runes("h"), "kube-helper › prod", []string{"alpha", "beta", "delta", "gamma"}},
+		{[]*tcell.EventKey{key(tcell.KeyLeft)}, "kube-helper", []string{"prod", "staging"}},
+		{[]*tcell.EventKey{key(tcell.KeyEscape)}, "kube-helper", []string{"prod", "staging"}},
+	}
+	for i, step := range steps {
+		press(t, ui, step.keys...)
+		if got := breadcrumbOf(screen); got != step.breadcrumb {
+			t.Errorf("step %d: breadcrumb %q, want %q", i, got, step.breadcrumb)
+		}
+		if got := shownRows(screen); strings.Join(got, ",") != strings.Join(step.rows, ",") {
+			t.Errorf("step %d: rows %v, want %v", i, got, step.rows)
+		}
+	}
+
+	// The selection is kept when coming back up.
+	press(t, ui, key(tcell.KeyEnter), key(tcell.KeyDown), key(tcell.KeyDown), key(tcell.KeyEnter), key(tcell.KeyEscape))
+	if selected := ui.view().selected; selected != 2 {
+		t.Errorf("selected row %d after going back, want 2", selected)
+	}
+}
+
+func TestTUISortKeys(t *testing.T) {
+	ui, screen := newTestTUI(t)
+	press(t, ui, key(tcell.KeyEnter))
+
+	want := map[string][]string{
+		"name":     {"alpha", "beta", "delta", "gamma"},
+		"cpu":      {"beta", "delta", "gamma", "alpha"},
+		"ram":      {"alpha", "delta", "gamma", "beta"},
+		"restarts": {"gamma", "delta", "beta", "alpha"},
+		"age":      {"delta", "gamma", "beta", "alpha"},
+	}
+	for i, sortKey := range append(tuiSortKeys, "name") {
+		if i > 0 {
+			press(t, ui, runes("s")...)
+		}
+		if !strings.HasSuffix(screenLine(screen, 0), "sort: "+sortKey+"  4 rows") {
+			t.Errorf("status line %q does not show sort %s", screenLine(screen, 0), sortKey)
+		}
+		if got := shownRows(screen); strings.Join(got, ",") != strings.Join(want[sortKey], ",") {
+			t.Errorf("sorted by %s: %v, want %v", sortKey, got, want[sortKey])
+		}
+	}
+}
+
+func TestTUIFilter(t *testing.T) {
+	ui, screen := newTestTUI(t)
+	_, _, height := screen.GetContents()
+	press(t, ui, key(tcell.KeyEnter))
+
+	press(t, ui, runes("/GA")...)
+	if got := shownRows(screen); strings.Join(got, ",") != "gamma" {
+		t.Errorf("filtering by GA shows %v, want [gamma]", got)
+	}
+	if footer := screenLine(screen, height-1); footer != "/GA" {
+		t.Errorf("footer %q while filtering, want /GA", footer)
+	}
+
+	// Backspace edits the filter, enter keeps it while browsing.
+	press(t, ui, key(tcell.KeyBackspace2), key(tcell.KeyBackspace2))
+	press(t, ui, runes("ta")...)
+	press(t, ui, key(tcell.KeyEnter))
+	if got := shownRows(screen); strings.Join(got, ",") != "beta,delta" {
+		t.Errorf("filtering by ta shows %v, want [beta delta]", got)
+	}
+	press(t, ui, key(tcell.KeyDown), key(tcell.KeyEnter))
+	if got := breadcrumbOf(screen); got != "kube-helper › prod › delta" {
+		t.Errorf("opened %q from the filtered rows, want delta", got)
+	}
+	if ui.filter != "" {
+		t.Errorf("filter %q carried into the next view", ui.filter)
+	}
+
+	// Esc clears a filter before it goes back.
+	press(t, ui, key(tcell.KeyEscape))
+	press(t, ui, runes("/zzz")...)
+	press(t, ui, key(tcell.KeyEnter))
+	if got := shownRows(screen); len(got) != 0 {
+		t.Errorf("filtering by zzz shows %v, want nothing", got)
+	}
+	press(t, ui, key(tcell.KeyEscape))
+	if got := breadcrumbOf(screen); got != "kube-helper › prod" {
+		t.Errorf("esc with a filter went to %q, want to stay in prod", got)
+	}
+	if got := shownRows(screen); len(got) != 4 {
+		t.Errorf("after clearing the filter %v, want all four namespaces", got)
+	}
+
+	// Esc while typing drops the filter.
+	press(t, ui, runes("/al")...)
+	press(t, ui, key(tcell.KeyEscape))
+	if ui.filtering || ui.filter != "" || len(shownRows(screen)) != 4 {
+		t.Errorf("esc while typing left filter %q", ui.filter)
+	}
+}
+
+func TestTUIRunQuits(t *testing.T) {
+	ui, screen := newTestTUI(t)
+	screen.InjectKey(tcell.KeyEnter, 0, tcell.ModNone)
+	screen.InjectKey(tcell.KeyRune, 'j', tcell.ModNone)
+	screen.InjectKey(tcell.KeyRune, 'l', tcell.ModNone)
+	screen.InjectKey(tcell.KeyRune, 'q', tcell.ModNone)
+	ui.run()
+	if got := breadcrumbOf(screen); got != "kube-helper › prod › beta" {
+		t.Errorf("run stopped at %q, want prod › beta", got)
+	}
+}
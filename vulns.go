@@ -0,0 +1,282 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+var severities = []string{"CRITICAL", "HIGH", "MEDIUM", "LOW", "UNKNOWN"}
+
+// advisory is the set of vulnerabilities known for one image, keyed by vulnerability ID.
+type advisory struct {
+	names   []string
+	digests []string
+	vulns   map[string]string
+}
+
+// advisoryDB indexes advisories by registry/repository:tag, registry/repository@digest and
+// bare digest so a running image can be found whichever way it was scanned.
+type advisoryDB struct {
+	index map[string]*advisory
+}
+
+// trivyReport is the part of `trivy image -f json` we need.
+type trivyReport struct {
+	ArtifactName string `json:"ArtifactName"`
+	Metadata     struct {
+		RepoTags    []string `json:"RepoTags"`
+		RepoDigests []string `json:"RepoDigests"`
+	} `json:"Metadata"`
+	Results []struct {
+		Vulnerabilities []struct {
+			VulnerabilityID string `json:"VulnerabilityID"`
+			Severity        string `json:"Severity"`
+		} `json:"Vulnerabilities"`
+	} `json:"Results"`
+}
+
+// grypeReport is the part of `grype -o json` we need.
+type grypeReport struct {
+	Source struct {
+		Target struct {
+			UserInput   string   `json:"userInput"`
+			Tags        []string `json:"tags"`
+			RepoDigests []string `json:"repoDigests"`
+		} `json:"target"`
+	} `json:"source"`
+	Matches []struct {
+		Vulnerability struct {
+			ID       string `json:"id"`
+			Severity string `json:"severity"`
+		} `json:"vulnerability"`
+	} `json:"matches"`
+}
+
+// plainAdvisory is our own minimal format for anything else that can be exported to JSON.
+type plainAdvisory struct {
+	Image           string   `json:"image"`
+	Digests         []string `json:"digests"`
+	Vulnerabilities []struct {
+		ID       string `json:"id"`
+		Severity string `json:"severity"`
+	} `json:"vulnerabilities"`
+}
+
+func normalizeSeverity(s string) string {
+	s = strings.ToUpper(s)
+	if s == "NEGLIGIBLE" {
+		return "LOW"
+	}
+	if contains(severities, s) {
+		return s
+	}
+	return "UNKNOWN"
+}
+
+// loadAdvisoryDB reads a JSON file, or every .json file in a directory. Each file may hold one
+// report or a list of them, from Trivy, Grype or the plain format.
+func loadAdvisoryDB(path string) (advisoryDB, error) {
+	db := advisoryDB{index: make(map[string]*advisory)}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return db, err
+	}
+	files := []string{path}
+	if info.IsDir() {
+		files, err = filepath.Glob(filepath.Join(path, "*.json"))
+		if err != nil {
+			return db, err
+		}
+	}
+
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return db, err
+		}
+		var raw []json.RawMessage
+		if err := json.Unmarshal(data, &raw); err != nil {
+			raw = []json.RawMessage{data}
+		}
+		for _, doc := range raw {
+			if err := db.addDocument(doc); err != nil {
+				return db, fmt.Errorf("%s: %v", file, err)
+			}
+		}
+	}
+	return db, nil
+}
+
+func (db advisoryDB) addDocument(doc json.RawMessage) error {
+	var keys map[string]json.RawMessage
+	if err := json.Unmarshal(doc, &keys); err != nil {
+		return err
+	}
+
+	a := &advisory{vulns: make(map[string]string)}
+	switch {
+	case keys["ArtifactName"] != nil:
+		var report trivyReport
+		if err := json.Unmarshal(doc, &report); err != nil {
+			return err
+		}
+		a.names = append([]string{report.ArtifactName}, report.Metadata.RepoTags...)
+		a.digests = report.Metadata.RepoDigests
+		for _, result := range report.Results {
+			for _, v := range result.Vulnerabilities {
+				a.vulns[v.VulnerabilityID] = normalizeSeverity(v.Severity)
+			}
+		}
+	case keys["matches"] != nil:
+		var report grypeReport
+		if err := json.Unmarshal(doc, &report); err != nil {
+			return err
+		}
+		a.names = append([]string{report.Source.Target.UserInput}, report.Source.Target.Tags...)
+		a.digests = report.Source.Target.RepoDigests
+		for _, m := range report.Matches {
+			a.vulns[m.Vulnerability.ID] = normalizeSeverity(m.Vulnerability.Severity)
+		}
+	case keys["image"] != nil:
+		var report plainAdvisory
+		if err := json.Unmarshal(doc, &report); err != nil {
+			return err
+		}
+		a.names = []string{report.Image}
+		a.digests = report.Digests
+		for _, v := range report.Vulnerabilities {
+			a.vulns[v.ID] = normalizeSeverity(v.Severity)
+		}
+	default:
+		return fmt.Errorf("not a Trivy, Grype or plain advisory report")
+	}
+
+	for _, name := range a.names {
+		if ref, err := parseImageRef(name); err == nil {
+			db.index[ref.String()] = a
+		}
+	}
+	for _, digest := range a.digests {
+		// RepoDigests look like repo@sha256:..., index both the full and the bare digest.
+		if ref, err := parseImageRef(digest); err == nil {
+			db.index[ref.String()] = a
+		}
+		if bare := digestFromImageID(digest); bare != "" {
+			db.index[bare] = a
+		}
+	}
+	return nil
+}
+
+// lookup matches an image by what is actually running first, then what the spec asked for.
+func (db advisoryDB) lookup(info imageInfo) *advisory {
+	ref := imageRef{registry: info.imageRepo, repository: info.imageName}
+	for _, digest := range append(append([]string{}, info.runningDigests...), info.imageDigest) {
+		if digest == "" {
+			continue
+		}
+		if a, ok := db.index[imageRef{registry: ref.registry, repository: ref.repository, digest: digest}.String()]; ok {
+			return a
+		}
+		if a, ok := db.index[digest]; ok {
+			return a
+		}
+	}
+	if parsed, err := parseImageRef(info.imageKey); err == nil {
+		parsed.digest = ""
+		if parsed.tag == "" {
+			parsed.tag = "latest"
+		}
+		if a, ok := db.index[parsed.String()]; ok {
+			return a
+		}
+	}
+	return nil
+}
+
+func (a *advisory) severityCounts() map[string]int {
+	var counts = make(map[string]int)
+	for _, severity := range a.vulns {
+		counts[severity]++
+	}
+	return counts
+}
+
+// annotateVulnerabilities stores the CVE counts on every imageMap entry the database knows.
+func annotateVulnerabilities(imageMap map[string]imageInfo, db advisoryDB) {
+	for key, info := range imageMap {
+		if a := db.lookup(info); a != nil {
+			info.vulns = a.severityCounts()
+			info.scanned = true
+			imageMap[key] = info
+		}
+	}
+}
+
+func vulnSummary(counts map[string]int) string {
+	var parts []string
+	for _, severity := range severities {
+		if counts[severity] > 0 {
+			parts = append(parts, fmt.Sprintf("%c:%d", severity[0], counts[severity]))
+		}
+	}
+	if len(parts) == 0 {
+		return "clean"
+	}
+	return strings.Join(parts, " ")
+}
+
+// printVulnerableImages lists every image with known CVEs, worst first, and where it runs.
+func printVulnerableImages(imageMap map[string]imageInfo, clusters []clusterDetail) {
+	var goodColor = colorString(32, false)
+	var errorColor = colorString(31, false)
+	var warningColor = colorString(33, false)
+	var normalColor = colorString(37, false)
+	var darkGray = colorString(30, false)
+
+	var vulnerable []imageInfo
+	var unscanned int
+	for _, info := range imageMap {
+		if !info.scanned {
+			unscanned++
+		} else if len(info.vulns) > 0 {
+			vulnerable = append(vulnerable, info)
+		}
+	}
+	sort.Slice(vulnerable, func(i, j int) bool {
+		for _, severity := range severities {
+			if vulnerable[i].vulns[severity] != vulnerable[j].vulns[severity] {
+				return vulnerable[i].vulns[severity] > vulnerable[j].vulns[severity]
+			}
+		}
+		return vulnerable[i].imageKey < vulnerable[j].imageKey
+	})
+
+	fmt.Printf("\n%s===== %sVulnerable Images%s =====%s\n", darkGray, errorColor, darkGray, normalColor)
+	fmt.Printf(" %d images with known vulnerabilities, %d images not in the advisory database\n", len(vulnerable), unscanned)
+	for _, info := range vulnerable {
+		color := warningColor
+		if info.vulns["CRITICAL"] > 0 || info.vulns["HIGH"] > 0 {
+			color = errorColor
+		}
+		fmt.Printf("\n %s%s%s  %s\n", color, info.imageKey, normalColor, vulnSummary(info.vulns))
+		for _, cluster := range clusters {
+			for _, name := range sortedNamespaceNames(cluster.namespaces) {
+				ns := cluster.namespaces[name]
+				for _, pod := range ns.pods {
+					for _, container := range pod.containers {
+						if container.ref.key() == info.imageKey {
+							fmt.Printf("   %s- %s%s/%s/%s%s (%s) on %s\n", darkGray, goodColor, cluster.name, ns.name, pod.name, normalColor, container.name, pod.nodeName)
+						}
+					}
+				}
+			}
+		}
+	}
+	fmt.Println()
+}
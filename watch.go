@@ -0,0 +1,314 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/informers"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// watchedCluster keeps informer caches for one cluster so every refresh is built from memory
+// instead of listing the whole cluster again.
+type watchedCluster struct {
+	cluster         clusterDetail
+	pods            corelisters.PodLister
+	nodes           corelisters.NodeLister
+	namespaces      corelisters.NamespaceLister
+	virtualServices cache.GenericLister
+}
+
+// watchSnapshot is what a refresh is compared against to highlight changes.
+type watchSnapshot struct {
+	pending     map[string]bool
+	restarts    map[string]int32
+	nodes       map[string]bool
+	deployments map[string]int
+}
+
+func startInformers(cluster clusterDetail, stop <-chan struct{}) watchedCluster {
+	filter := cluster.filter
+	factory := informers.NewSharedInformerFactory(&cluster.clientset, 0)
+	// Pods and nodes get their own factories so the selectors are applied by the API server.
+	podFactory := informers.NewSharedInformerFactoryWithOptions(&cluster.clientset, 0, informers.WithTweakListOptions(func(options *metav1.ListOptions) {
+		podOptions := filter.podListOptions()
+		options.LabelSelector = podOptions.LabelSelector
+		options.FieldSelector = podOptions.FieldSelector
+	}))
+	nodeFactory := informers.NewSharedInformerFactoryWithOptions(&cluster.clientset, 0, informers.WithTweakListOptions(func(options *metav1.ListOptions) {
+		options.LabelSelector = filter.nodeSelector
+	}))
+	watched := watchedCluster{
+		cluster:    cluster,
+		pods:       podFactory.Core().V1().Pods().Lister(),
+		nodes:      nodeFactory.Core().V1().Nodes().Lister(),
+		namespaces: factory.Core().V1().Namespaces().Lister(),
+	}
+	factory.Start(stop)
+	podFactory.Start(stop)
+	nodeFactory.Start(stop)
+
+	// Only watch VirtualServices when Istio is installed, otherwise the informer never syncs.
+	if _, err := cluster.dynamicClient.Resource(virtualServiceGVR).List(context.TODO(), metav1.ListOptions{Limit: 1}); err == nil {
+		dynamicFactory := dynamicinformer.NewDynamicSharedInformerFactory(cluster.dynamicClient, 0)
+		watched.virtualServices = dynamicFactory.ForResource(virtualServiceGVR).Lister()
+		dynamicFactory.Start(stop)
+		dynamicFactory.WaitForCacheSync(stop)
+	}
+
+	factory.WaitForCacheSync(stop)
+	podFactory.WaitForCacheSync(stop)
+	nodeFactory.WaitForCacheSync(stop)
+	return watched
+}
+
+// refresh builds the cluster model from the informer caches with the same collectors the
+// one-shot scan uses.
+func (w watchedCluster) refresh() clusterDetail {
+	cluster := w.cluster
+	nsDetails := make(map[string]nameSpaceDetail)
+
+	namespaces, _ := w.namespaces.List(labels.Everything())
+	for _, ns := range namespaces {
+		if w.cluster.filter.includesNamespace(ns.Name) {
+			nsDetails[ns.Name] = nameSpaceDetail{name: ns.Name, labels: ns.Labels, created: ns.CreationTimestamp.Time}
+		}
+	}
+
+	if w.virtualServices != nil {
+		virtualServices, _ := w.virtualServices.List(labels.Everything())
+		for _, obj := range virtualServices {
+			if vs, ok := obj.(*unstructured.Unstructured); ok && w.cluster.filter.includesNamespace(vs.GetNamespace()) {
+				thisNS := nsDetails[vs.GetNamespace()]
+				thisNS.virtualServices = append(thisNS.virtualServices, *vs)
+				nsDetails[vs.GetNamespace()] = thisNS
+			}
+		}
+	}
+
+	pods, _ := w.pods.List(labels.Everything())
+	for _, pod := range pods {
+		if w.cluster.filter.includesNamespace(pod.Namespace) {
+			addPod(nsDetails, *pod, nil)
+		}
+	}
+
+	nodes, _ := w.nodes.List(labels.Everything())
+	cluster.nodeList = nil
+	for _, node := range nodes {
+		cluster.nodeList = append(cluster.nodeList, *node)
+	}
+	sort.Slice(cluster.nodeList, func(i, j int) bool { return cluster.nodeList[i].Name < cluster.nodeList[j].Name })
+
+	cluster.namespaces = nsDetails
+	return cluster
+}
+
+func takeSnapshot(cluster clusterDetail) watchSnapshot {
+	snapshot := watchSnapshot{
+		pending:     make(map[string]bool),
+		restarts:    make(map[string]int32),
+		nodes:       make(map[string]bool),
+		deployments: make(map[string]int),
+	}
+	for _, ns := range cluster.namespaces {
+		for _, pod := range ns.pods {
+			key := ns.name + "/" + pod.name
+			if pod.status == "Pending" {
+				snapshot.pending[key] = true
+			}
+			snapshot.restarts[key] = pod.RestartCount
+		}
+		for _, deployment := range ns.deployments {
+			snapshot.deployments[ns.name+"/"+deployment.name] = deployment.count
+		}
+	}
+	for _, node := range cluster.nodeList {
+		snapshot.nodes[node.Name] = true
+	}
+	return snapshot
+}
+
+func sortedKeys(m map[string]bool) []string {
+	var keys []string
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// renderWatch draws one cluster's dashboard. prev is nil on the first refresh.
+func renderWatch(cluster clusterDetail, prev *watchSnapshot, cur watchSnapshot, interval time.Duration) {
+	var goodColor = colorString(32, false)
+	var errorColor = colorString(31, false)
+	var warningColor = colorString(33, false)
+	var changedColor = colorString(33, true)
+	var normalColor = colorString(37, false)
+	var darkGray = colorString(30, false)
+
+	fmt.Printf("%s===== %s%s%s ===== %s, refreshing every %s%s\n", darkGray, goodColor, cluster.name, darkGray, time.Now().Format("15:04:05"), interval, normalColor)
+
+	// Changes since the last refresh.
+	var changedNamespaces = make(map[string]bool)
+	if prev != nil {
+		var changes []string
+		for _, node := range sortedKeys(cur.nodes) {
+			if !prev.nodes[node] {
+				changes = append(changes, fmt.Sprintf("%s+ node %s added%s", goodColor, node, normalColor))
+			}
+		}
+		for _, node := range sortedKeys(prev.nodes) {
+			if !cur.nodes[node] {
+				changes = append(changes, fmt.Sprintf("%s- node %s removed%s", errorColor, node, normalColor))
+			}
+		}
+		for _, pod := range sortedKeys(cur.pending) {
+			if !prev.pending[pod] {
+				changes = append(changes, fmt.Sprintf("%s! %s is pending%s", errorColor, pod, normalColor))
+				changedNamespaces[strings.SplitN(pod, "/", 2)[0]] = true
+			}
+		}
+		var restarted []string
+		for pod, restarts := range cur.restarts {
+			if before, ok := prev.restarts[pod]; ok && restarts > before {
+				restarted = append(restarted, fmt.Sprintf("%s↻ %s restarted %d times (now %d)%s", warningColor, pod, restarts-before, restarts, normalColor))
+				changedNamespaces[strings.SplitN(pod, "/", 2)[0]] = true
+			}
+		}
+		sort.Strings(restarted)
+		changes = append(changes, restarted...)
+		if len(changes) == 0 {
+			fmt.Printf(" %sno changes since the last refresh%s\n", darkGray, normalColor)
+		}
+		for _, change := range changes {
+			fmt.Printf(" %s\n", change)
+		}
+	}
+
+	// Namespaces
+	var names []string
+	width := 9
+	for name, ns := range cluster.namespaces {
+		if len(ns.pods) == 0 {
+			continue
+		}
+		names = append(names, name)
+		if len(name) > width {
+			width = len(name)
+		}
+	}
+	sort.Strings(names)
+	fmt.Printf("\n %s%-*s %5s %8s %8s %7s %8s %9s%s\n", darkGray, width, "Namespace", "Pods", "Running", "Pending", "Failed", "CPU", "RAM", normalColor)
+	for _, name := range names {
+		ns := cluster.namespaces[name]
+		rowColor := normalColor
+		if changedNamespaces[name] {
+			rowColor = changedColor
+		}
+		pendingColor := rowColor
+		if ns.statusSummary.pending > 0 {
+			pendingColor = errorColor
+		}
+		fmt.Printf(" %s%-*s %5d %8d %s%8d%s %7d %7dm %6d MB%s\n", rowColor, width, name, len(ns.pods),
+			ns.statusSummary.running, pendingColor, ns.statusSummary.pending, rowColor, ns.statusSummary.failed,
+			ns.totalCPURequest, ns.totalRAMRequest/1024/1024, normalColor)
+	}
+
+	// Nodes
+	var podsOnNode = make(map[string]podInfo)
+	for _, ns := range cluster.namespaces {
+		for _, pod := range ns.pods {
+			if pod.status == "Completed" || pod.status == "Failed" {
+				continue
+			}
+			podsOnNode[pod.nodeName] = podInfo{
+				count:          podsOnNode[pod.nodeName].count + 1,
+				reservedCPU:    podsOnNode[pod.nodeName].reservedCPU + pod.reservedCPU,
+				reservedMemory: podsOnNode[pod.nodeName].reservedMemory + pod.reservedMemory,
+			}
+		}
+	}
+	fmt.Printf("\n %s%s %-12s %-14s %5s %6s %6s%s\n", darkGray, fitColumnLeft("Node", 48), "Group", "Type", "Pods", "CPU", "RAM", normalColor)
+	for _, node := range cluster.nodeList {
+		rowColor := normalColor
+		if prev != nil && !prev.nodes[node.Name] {
+			rowColor = changedColor
+		}
+		usage := podsOnNode[node.Name]
+		fmt.Printf(" %s%s %-12s %-14s %5d %5.0f%% %5.0f%%%s\n", rowColor, fitColumnLeft(node.Name, 48), nodeGroupOf(node),
+			node.GetLabels()["beta.kubernetes.io/instance-type"], usage.count,
+			100*float64(usage.reservedCPU)/float64(node.Status.Allocatable.Cpu().MilliValue()+1),
+			100*float64(usage.reservedMemory)/float64(node.Status.Allocatable.Memory().Value()+1),
+			normalColor)
+	}
+
+	// Deployments
+	var workloads []string
+	for key := range cur.deployments {
+		workloads = append(workloads, key)
+	}
+	sort.Strings(workloads)
+	fmt.Printf("\n %s%s %5s%s\n", darkGray, fitColumnLeft("Workload", 64), "Pods", normalColor)
+	for _, key := range workloads {
+		delta := ""
+		rowColor := normalColor
+		if prev != nil && prev.deployments[key] != cur.deployments[key] {
+			delta = fmt.Sprintf(" (%+d)", cur.deployments[key]-prev.deployments[key])
+			rowColor = changedColor
+		}
+		fmt.Printf(" %s%s %5d%s%s\n", rowColor, fitColumnLeft(key, 64), cur.deployments[key], delta, normalColor)
+	}
+	fmt.Println()
+}
+
+// runWatch keeps informers running for every cluster and redraws the dashboard in place until
+// interrupted, checking the alert rules after every refresh.
+func runWatch(clusters []clusterDetail, interval time.Duration, alerts *alerter) {
+	stop := make(chan struct{})
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+
+	fmt.Println("Starting informers...")
+	var watched []watchedCluster
+	for _, cluster := range clusters {
+		watched = append(watched, startInformers(cluster, stop))
+	}
+
+	var previous = make([]*watchSnapshot, len(watched))
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		fmt.Print(output.clear())
+		var refreshed []clusterDetail
+		for i, w := range watched {
+			cluster := w.refresh()
+			snapshot := takeSnapshot(cluster)
+			renderWatch(cluster, previous[i], snapshot, interval)
+			previous[i] = &snapshot
+			refreshed = append(refreshed, cluster)
+		}
+		if alerts != nil {
+			if err := alerts.check(refreshed, true); err != nil {
+				fmt.Printf(" %salerting: %v%s\n", colorString(31, false), err, colorString(37, false))
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-interrupt:
+			close(stop)
+			return
+		}
+	}
+}
@@ -0,0 +1,312 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"sort"
+
+	v1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/yaml"
+)
+
+const hoursPerMonth = 730
+
+// instanceShape is one entry of the user supplied what-if catalog. Price is hourly.
+type instanceShape struct {
+	Name      string  `json:"name"`
+	VCPU      float64 `json:"vcpu"`
+	MemoryGiB float64 `json:"memoryGiB"`
+	Price     float64 `json:"price"`
+	MaxPods   int     `json:"maxPods"`
+}
+
+type instanceCatalog struct {
+	Instances []instanceShape `json:"instances"`
+}
+
+// nodeGroupUsage is everything we know about one workload_type group of nodes.
+type nodeGroupUsage struct {
+	name          string
+	nodes         int
+	instanceTypes map[string]int
+	capCPU        int64
+	capRAM        int64
+	allocCPU      int64
+	allocRAM      int64
+	allocPods     int64
+	daemonCPU     int64
+	daemonRAM     int64
+	daemonPods    int
+	pods          []podInfo
+}
+
+type whatIfResult struct {
+	shape       instanceShape
+	nodes       int
+	unplaceable int
+	cpuUsed     float64
+	ramUsed     float64
+}
+
+// loadInstanceCatalog reads a YAML or JSON catalog of instance shapes.
+func loadInstanceCatalog(path string) ([]instanceShape, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var catalog instanceCatalog
+	if err := yaml.Unmarshal(data, &catalog); err != nil {
+		return nil, fmt.Errorf("parsing %s: %v", path, err)
+	}
+	for _, shape := range catalog.Instances {
+		if shape.Name == "" || shape.VCPU <= 0 || shape.MemoryGiB <= 0 {
+			return nil, fmt.Errorf("%s: every instance needs a name, vcpu and memoryGiB", path)
+		}
+	}
+	return catalog.Instances, nil
+}
+
+// nodeGroupOf returns the node group taint (workload_type unless configured) of a node, or "default" like the Node Breakdown does.
+func nodeGroupOf(node v1.Node) string {
+	for _, taint := range node.Spec.Taints {
+		if taint.Key == settings.NodeGroupKey {
+			return taint.Value
+		}
+	}
+	return "default"
+}
+
+// podGroupOf places a pod in the group of the node it runs on. Pods that are not scheduled yet
+// go to the group they tolerate.
+func podGroupOf(pod podInfo, nodeGroups map[string]string) string {
+	if group, ok := nodeGroups[pod.nodeName]; ok {
+		return group
+	}
+	for _, toleration := range pod.tolerations {
+		if toleration.Key == settings.NodeGroupKey && toleration.Value != "" {
+			return toleration.Value
+		}
+	}
+	return "default"
+}
+
+func groupNodeUsage(cluster clusterDetail) map[string]*nodeGroupUsage {
+	var groups = make(map[string]*nodeGroupUsage)
+	var nodeGroups = make(map[string]string)
+
+	getGroup := func(name string) *nodeGroupUsage {
+		if _, ok := groups[name]; !ok {
+			groups[name] = &nodeGroupUsage{name: name, instanceTypes: make(map[string]int)}
+		}
+		return groups[name]
+	}
+
+	for _, node := range cluster.nodeList {
+		group := getGroup(nodeGroupOf(node))
+		nodeGroups[node.Name] = group.name
+		group.nodes++
+		group.instanceTypes[node.GetLabels()["beta.kubernetes.io/instance-type"]]++
+		group.capCPU += node.Status.Capacity.Cpu().MilliValue()
+		group.capRAM += node.Status.Capacity.Memory().Value()
+		group.allocCPU += node.Status.Allocatable.Cpu().MilliValue()
+		group.allocRAM += node.Status.Allocatable.Memory().Value()
+		group.allocPods += node.Status.Allocatable.Pods().Value()
+	}
+
+	for _, ns := range cluster.namespaces {
+		for _, pod := range ns.pods {
+			if pod.Phase == v1.PodSucceeded || pod.Phase == v1.PodFailed {
+				continue
+			}
+			group := getGroup(podGroupOf(pod, nodeGroups))
+			if pod.ownerKind == "DaemonSet" {
+				group.daemonCPU += pod.reservedCPU
+				group.daemonRAM += pod.reservedMemory
+				group.daemonPods++
+				continue
+			}
+			group.pods = append(group.pods, pod)
+		}
+	}
+
+	return groups
+}
+
+// ratio returns part/whole, or 1 when there is nothing to compare against.
+func ratio(part int64, whole int64) float64 {
+	if whole == 0 {
+		return 1
+	}
+	return float64(part) / float64(whole)
+}
+
+// packPods does a first-fit-decreasing bin pack of pods onto identical nodes and returns how many
+// nodes were needed, how many pods could not fit even on an empty node, and the requests placed.
+func packPods(pods []podInfo, nodeCPU int64, nodeRAM int64, nodePods int) (int, int, int64, int64) {
+	type bin struct {
+		cpu  int64
+		ram  int64
+		pods int
+	}
+	var bins []bin
+	var unplaceable int
+	var placedCPU, placedRAM int64
+
+	if nodeCPU <= 0 || nodeRAM <= 0 || nodePods <= 0 {
+		return 0, len(pods), 0, 0
+	}
+
+	sorted := make([]podInfo, len(pods))
+	copy(sorted, pods)
+	share := func(p podInfo) float64 {
+		return math.Max(float64(p.reservedCPU)/float64(nodeCPU), float64(p.reservedMemory)/float64(nodeRAM))
+	}
+	sort.SliceStable(sorted, func(i, j int) bool { return share(sorted[i]) > share(sorted[j]) })
+
+	for _, pod := range sorted {
+		if pod.reservedCPU > nodeCPU || pod.reservedMemory > nodeRAM {
+			unplaceable++
+			continue
+		}
+		placedCPU += pod.reservedCPU
+		placedRAM += pod.reservedMemory
+		placed := false
+		for b := range bins {
+			if bins[b].cpu >= pod.reservedCPU && bins[b].ram >= pod.reservedMemory && bins[b].pods > 0 {
+				bins[b].cpu -= pod.reservedCPU
+				bins[b].ram -= pod.reservedMemory
+				bins[b].pods--
+				placed = true
+				break
+			}
+		}
+		if !placed {
+			bins = append(bins, bin{cpu: nodeCPU - pod.reservedCPU, ram: nodeRAM - pod.reservedMemory, pods: nodePods - 1})
+		}
+	}
+
+	return len(bins), unplaceable, placedCPU, placedRAM
+}
+
+// whatIf packs a group's pods onto each catalog shape. Catalog sizes are derated by the
+// allocatable/capacity ratio seen on the group's current nodes, and DaemonSet requests are
+// taken off every node before packing.
+func whatIf(group *nodeGroupUsage, catalog []instanceShape) []whatIfResult {
+	var results []whatIfResult
+	cpuRatio := ratio(group.allocCPU, group.capCPU)
+	ramRatio := ratio(group.allocRAM, group.capRAM)
+	perNodeDaemonCPU, perNodeDaemonRAM, perNodeDaemonPods := int64(0), int64(0), 0
+	if group.nodes > 0 {
+		perNodeDaemonCPU = group.daemonCPU / int64(group.nodes)
+		perNodeDaemonRAM = group.daemonRAM / int64(group.nodes)
+		perNodeDaemonPods = group.daemonPods / group.nodes
+	}
+
+	for _, shape := range catalog {
+		nodeCPU := int64(shape.VCPU*1000*cpuRatio) - perNodeDaemonCPU
+		nodeRAM := int64(shape.MemoryGiB*1024*1024*1024*ramRatio) - perNodeDaemonRAM
+		maxPods := shape.MaxPods
+		if maxPods == 0 {
+			maxPods = 110
+		}
+		nodes, unplaceable, placedCPU, placedRAM := packPods(group.pods, nodeCPU, nodeRAM, maxPods-perNodeDaemonPods)
+		result := whatIfResult{shape: shape, nodes: nodes, unplaceable: unplaceable}
+		if nodes > 0 {
+			result.cpuUsed = float64(placedCPU) / float64(nodeCPU*int64(nodes))
+			result.ramUsed = float64(placedRAM) / float64(nodeRAM*int64(nodes))
+		}
+		results = append(results, result)
+	}
+
+	// A shape that leaves pods behind is no replacement however cheap it looks, and one too small
+	// for every pod needs no nodes at all, so shapes that fit everything come first.
+	sort.SliceStable(results, func(i, j int) bool {
+		if fitsI, fitsJ := results[i].unplaceable == 0, results[j].unplaceable == 0; fitsI != fitsJ {
+			return fitsI
+		}
+		return float64(results[i].nodes)*results[i].shape.Price < float64(results[j].nodes)*results[j].shape.Price
+	})
+	return results
+}
+
+// currentMonthlyCost prices the group's current nodes from the catalog, if the catalog knows them.
+func currentMonthlyCost(group *nodeGroupUsage, catalog []instanceShape) (float64, bool) {
+	var cost float64
+	for instanceType, count := range group.instanceTypes {
+		found := false
+		for _, shape := range catalog {
+			if shape.Name == instanceType {
+				cost += float64(count) * shape.Price * hoursPerMonth
+				found = true
+				break
+			}
+		}
+		if !found {
+			return 0, false
+		}
+	}
+	return cost, true
+}
+
+func printWhatIf(cluster clusterDetail, catalog []instanceShape) {
+	var goodColor = colorString(32, false)
+	var errorColor = colorString(31, false)
+	var warningColor = colorString(33, false)
+	var normalColor = colorString(37, false)
+	var darkGray = colorString(30, false)
+
+	groups := groupNodeUsage(cluster)
+	var names []string
+	for name := range groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Printf("\n%s===== %sWhat-If Node Shapes%s =====%s\n", darkGray, goodColor, darkGray, normalColor)
+	for _, name := range names {
+		group := groups[name]
+		var totalCPU, totalRAM int64
+		for _, pod := range group.pods {
+			totalCPU += pod.reservedCPU
+			totalRAM += pod.reservedMemory
+		}
+		var instanceTypes []string
+		for instanceType := range group.instanceTypes {
+			instanceTypes = append(instanceTypes, instanceType)
+		}
+		sort.Strings(instanceTypes)
+		var types string
+		for _, instanceType := range instanceTypes {
+			types += fmt.Sprintf(" %dx %s", group.instanceTypes[instanceType], instanceType)
+		}
+		fmt.Printf("\n %s%s%s: %d nodes (%s ), %d pods requesting %dm vCPU, %d GiB RAM (+%d DaemonSet pods)\n",
+			goodColor, name, normalColor, group.nodes, types, len(group.pods), totalCPU, totalRAM/1024/1024/1024, group.daemonPods)
+
+		current, priced := currentMonthlyCost(group, catalog)
+		for _, result := range whatIf(group, catalog) {
+			deltaColor := goodColor
+			if result.nodes > group.nodes {
+				deltaColor = warningColor
+			}
+			shapeColor := normalColor
+			if result.unplaceable > 0 {
+				shapeColor, deltaColor = errorColor, errorColor
+			}
+			fmt.Printf("\t%s%24s%s: %s%4d nodes (%+d)%s  CPU %3.0f%%  RAM %3.0f%%  $%10.2f/mo",
+				shapeColor, result.shape.Name, normalColor,
+				deltaColor, result.nodes, result.nodes-group.nodes, normalColor,
+				result.cpuUsed*100, result.ramUsed*100,
+				float64(result.nodes)*result.shape.Price*hoursPerMonth,
+			)
+			if priced {
+				fmt.Printf(" (%+.2f)", float64(result.nodes)*result.shape.Price*hoursPerMonth-current)
+			}
+			if result.unplaceable > 0 {
+				fmt.Printf(" %s✗ %d pods do not fit%s", errorColor, result.unplaceable, normalColor)
+			}
+			fmt.Println()
+		}
+	}
+	fmt.Println()
+}
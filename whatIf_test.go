@@ -0,0 +1,34 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWhatIfRanksShapesThatFitFirst(t *testing.T) {
+	var pods []podInfo
+	for i := 0; i < 4; i++ {
+		pods = append(pods, podInfo{reservedCPU: 1500, reservedMemory: 2 * gib})
+	}
+	pods = append(pods, podInfo{reservedCPU: 6000, reservedMemory: 8 * gib})
+	group := &nodeGroupUsage{nodes: 2, capCPU: 1, allocCPU: 1, capRAM: 1, allocRAM: 1, pods: pods}
+
+	catalog := []instanceShape{
+		{Name: "large", VCPU: 8, MemoryGiB: 32, Price: 0.40},
+		{Name: "tiny", VCPU: 1, MemoryGiB: 1, Price: 0.01},
+		{Name: "medium", VCPU: 4, MemoryGiB: 16, Price: 0.20},
+		{Name: "xlarge", VCPU: 16, MemoryGiB: 64, Price: 0.70},
+	}
+	var order []string
+	for _, result := range whatIf(group, catalog) {
+		order = append(order, result.shape.Name)
+		if fits := result.unplaceable == 0; fits != (result.shape.Name == "large" || result.shape.Name == "xlarge") {
+			t.Errorf("%s: %d pods do not fit", result.shape.Name, result.unplaceable)
+		}
+	}
+	// tiny holds no pod at all and medium not the big one, so both come last even though their
+	// nodes cost less.
+	if got := strings.Join(order, ","); got != "xlarge,large,tiny,medium" {
+		t.Errorf("shapes ranked %s, want xlarge,large,tiny,medium", got)
+	}
+}